@@ -9,10 +9,15 @@ import (
 	_ "github.com/tiny-systems/common-module/components/async"
 	_ "github.com/tiny-systems/common-module/components/debug"
 	_ "github.com/tiny-systems/common-module/components/delay"
+	_ "github.com/tiny-systems/common-module/components/eventemitter"
+	_ "github.com/tiny-systems/common-module/components/gather"
+	_ "github.com/tiny-systems/common-module/components/htmltemplate"
 	_ "github.com/tiny-systems/common-module/components/kv"
 	_ "github.com/tiny-systems/common-module/components/mixer"
 	_ "github.com/tiny-systems/common-module/components/modify"
+	_ "github.com/tiny-systems/common-module/components/priorityqueue"
 	_ "github.com/tiny-systems/common-module/components/router"
+	_ "github.com/tiny-systems/common-module/components/scatter"
 	_ "github.com/tiny-systems/common-module/components/scheduler"
 	_ "github.com/tiny-systems/common-module/components/signal"
 	_ "github.com/tiny-systems/common-module/components/split"
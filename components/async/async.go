@@ -6,6 +6,8 @@ import (
 	"github.com/tiny-systems/module/module"
 	"github.com/tiny-systems/module/registry"
 	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
 )
 
 const (
@@ -16,39 +18,171 @@ const (
 
 type Context any
 
+type Settings struct {
+	BufferSize      int `json:"bufferSize" title:"Buffer size" description:"Messages are buffered and sent in batches of this size from a single goroutine. 1 or less sends each message from its own goroutine" minimum:"0" default:"0"`
+	FlushIntervalMs int `json:"flushIntervalMs" title:"Flush interval (ms)" description:"Buffered messages are sent after this time even if buffer is not full. Zero means messages wait until buffer is full" minimum:"0" default:"0"`
+}
+
 type InMessage struct {
 	Context Context `json:"context" configurable:"true" required:"true" title:"Context" description:"Arbitrary message to be modified"`
 }
 
+// message is a buffered message waiting to be sent
+type message struct {
+	ctx     context.Context
+	handler module.Handler
+	context Context
+}
+
 type Component struct {
+	settings Settings
+
+	lock   *sync.Mutex
+	buffer []message
+	timer  *time.Timer
+	// generation is increased on each flush, so timer fired for already flushed buffer is ignored
+	generation int
+
+	// pending batches are sent in order by a single flushing goroutine
+	pending  [][]message
+	flushing bool
+
+	// emit sends batch of buffered messages
+	emit func(batch []message)
 }
 
 func (t *Component) Instance() module.Component {
-	return &Component{}
+	c := &Component{
+		lock: &sync.Mutex{},
+	}
+	c.emit = c.send
+	return c
 }
 
 func (t *Component) GetInfo() module.ComponentInfo {
 	return module.ComponentInfo{
 		Name:        ComponentName,
 		Description: "Async",
-		Info:        "Asynchronously sends a new message after incoming message received.",
+		Info:        "Asynchronously sends a new message after incoming message received. Messages can be buffered and sent in batches.",
 		Tags:        []string{"SDK"},
 	}
 }
 
 func (t *Component) Handle(ctx context.Context, handler module.Handler, port string, msg interface{}) error {
+	if port == module.SettingsPort {
+		in, ok := msg.(Settings)
+		if !ok {
+			return fmt.Errorf("invalid settings")
+		}
+		t.lock.Lock()
+		t.settings = in
+		t.lock.Unlock()
+		// messages buffered with previous settings should not wait
+		t.flush()
+		return nil
+	}
+
 	if in, ok := msg.(InMessage); ok {
-		// @todo goroutine leak
-		go func() {
-			_ = handler(trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx)), OutPort, in.Context)
-		}()
+		m := message{
+			ctx:     trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx)),
+			handler: handler,
+			context: in.Context,
+		}
+
+		t.lock.Lock()
+		if t.settings.BufferSize <= 1 {
+			t.lock.Unlock()
+			// @todo goroutine leak
+			go t.emit([]message{m})
+			return nil
+		}
+
+		t.buffer = append(t.buffer, m)
+		if len(t.buffer) < t.settings.BufferSize {
+			if len(t.buffer) == 1 && t.settings.FlushIntervalMs > 0 {
+				gen := t.generation
+				t.timer = time.AfterFunc(time.Duration(t.settings.FlushIntervalMs)*time.Millisecond, func() {
+					t.flushGeneration(gen)
+				})
+			}
+			t.lock.Unlock()
+			return nil
+		}
+		t.flushLocked()
+		t.lock.Unlock()
 		return nil
 	}
 	return fmt.Errorf("invalid message")
 }
 
+// flush hands buffered messages over to the flushing goroutine
+func (t *Component) flush() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.flushLocked()
+}
+
+// flushGeneration flushes buffer only if it was not flushed since timer started
+func (t *Component) flushGeneration(gen int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if gen != t.generation {
+		return
+	}
+	t.flushLocked()
+}
+
+func (t *Component) flushLocked() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	if len(t.buffer) == 0 {
+		return
+	}
+
+	t.generation++
+	t.pending = append(t.pending, t.buffer)
+	t.buffer = nil
+	if t.flushing {
+		return
+	}
+	t.flushing = true
+	go t.drain()
+}
+
+// drain sends pending batches one after another until none left
+func (t *Component) drain() {
+	for {
+		t.lock.Lock()
+		if len(t.pending) == 0 {
+			t.flushing = false
+			t.lock.Unlock()
+			return
+		}
+		batch := t.pending[0]
+		t.pending = t.pending[1:]
+		t.lock.Unlock()
+
+		t.emit(batch)
+	}
+}
+
+// send sends messages one by one
+func (t *Component) send(batch []message) {
+	for _, m := range batch {
+		_ = m.handler(m.ctx, OutPort, m.context)
+	}
+}
+
 func (t *Component) Ports() []module.Port {
 	return []module.Port{
+		{
+			Name:          module.SettingsPort,
+			Label:         "Settings",
+			Source:        true,
+			Configuration: t.settings,
+		},
 		{
 			Name:          InPort,
 			Label:         "In",
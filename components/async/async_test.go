@@ -0,0 +1,145 @@
+package async
+
+import (
+	"context"
+	"github.com/tiny-systems/module/module"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestComponent_Buffer(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{BufferSize: 10})
+
+	var (
+		lock     sync.Mutex
+		batches  []int
+		received []int
+		wg       sync.WaitGroup
+	)
+	emit := c.emit
+	c.emit = func(batch []message) {
+		lock.Lock()
+		batches = append(batches, len(batch))
+		lock.Unlock()
+		emit(batch)
+	}
+
+	wg.Add(100)
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		lock.Lock()
+		received = append(received, data.(int))
+		lock.Unlock()
+		wg.Done()
+		return nil
+	}
+	for i := 0; i < 100; i++ {
+		if err := c.Handle(context.Background(), handler, InPort, InMessage{Context: i}); err != nil {
+			t.Fatalf("handle error: %v", err)
+		}
+	}
+	wg.Wait()
+
+	if len(batches) != 10 {
+		t.Errorf("expected 10 flushes, got %d", len(batches))
+	}
+	for _, size := range batches {
+		if size != 10 {
+			t.Errorf("expected batches of 10 messages, got %v", batches)
+			break
+		}
+	}
+	slices.Sort(received)
+	for i, v := range received {
+		if v != i {
+			t.Fatalf("messages were lost: %v", received)
+		}
+	}
+}
+
+func TestComponent_FlushInterval(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{BufferSize: 10, FlushIntervalMs: 20})
+
+	received := make(chan interface{}, 3)
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		received <- data
+		return nil
+	}
+	for i := 0; i < 3; i++ {
+		_ = c.Handle(context.Background(), handler, InPort, InMessage{Context: i})
+	}
+
+	timeout := time.After(time.Second)
+	for i := 0; i < 3; i++ {
+		select {
+		case v := <-received:
+			if v != i {
+				t.Errorf("expected message %d, got %v", i, v)
+			}
+		case <-timeout:
+			t.Fatal("buffered messages were not flushed")
+		}
+	}
+}
+
+func TestComponent_StaleTimer(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{BufferSize: 3, FlushIntervalMs: 1000})
+
+	batches := make(chan int, 3)
+	c.emit = func(batch []message) {
+		batches <- len(batch)
+	}
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		return nil
+	}
+	for i := 0; i < 3; i++ {
+		_ = c.Handle(context.Background(), handler, InPort, InMessage{Context: i})
+	}
+	_ = c.Handle(context.Background(), handler, InPort, InMessage{Context: 3})
+
+	// timer started for the first buffer fires after buffer was already flushed by size
+	c.flushGeneration(0)
+
+	if size := <-batches; size != 3 {
+		t.Fatalf("expected full batch of 3 messages, got %d", size)
+	}
+	select {
+	case size := <-batches:
+		t.Fatalf("stale timer flushed next buffer early with %d messages", size)
+	case <-time.After(50 * time.Millisecond):
+	}
+	c.flush()
+	if size := <-batches; size != 1 {
+		t.Errorf("expected remaining message to be flushed, got %d", size)
+	}
+}
+
+func TestComponent_BatchOrder(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{BufferSize: 2})
+
+	received := make(chan interface{}, 100)
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		received <- data
+		return nil
+	}
+	for i := 0; i < 100; i++ {
+		_ = c.Handle(context.Background(), handler, InPort, InMessage{Context: i})
+	}
+
+	timeout := time.After(time.Second)
+	for i := 0; i < 100; i++ {
+		select {
+		case v := <-received:
+			if v != i {
+				t.Fatalf("expected message %d, got %v", i, v)
+			}
+		case <-timeout:
+			t.Fatal("buffered messages were not sent")
+		}
+	}
+}
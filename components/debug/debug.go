@@ -2,20 +2,46 @@ package debug
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/tiny-systems/module/module"
 	"github.com/tiny-systems/module/registry"
+	"go.opentelemetry.io/otel/trace"
+	"reflect"
+	"slices"
+	"time"
+	"unicode/utf8"
 )
 
 const (
-	ComponentName        = "debug"
-	InPort        string = "in"
+	ComponentName         = "debug"
+	InPort         string = "in"
+	DumpPort       string = "dump"
+	DumpResultPort string = "dump_result"
 )
 
+const (
+	LogLevelOff   = "off"
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+)
+
+// maxLogBytes limits logged message if display limit is not set
+const maxLogBytes = 1024
+
 type Context any
 
 type Settings struct {
-	Context Context `json:"context" configurable:"true" required:"true" title:"Context" description:"Component message"`
+	Context         Context `json:"context" configurable:"true" required:"true" title:"Context" description:"Component message"`
+	ShowDiff        bool    `json:"showDiff" title:"Show diff" description:"Show which fields changed compared to the previous message. Decoded copy of the previous message is kept in memory for comparison regardless of max display bytes"`
+	PrettyPrint     bool    `json:"prettyPrint" title:"Pretty print" description:"Show message as indented JSON"`
+	MaxDisplayBytes int     `json:"maxDisplayBytes" title:"Max display bytes" description:"Longer messages are shown as truncated JSON. Zero means no limit" minimum:"0" default:"0"`
+	EnableDumpPort  bool    `json:"enableDumpPort" title:"Enable dump port" description:"Captured messages can be sent further by dump button or dump port"`
+	ClearAfterDump  bool    `json:"clearAfterDump" title:"Clear after dump" description:"Clear captured messages after they were dumped"`
+	LogLevel        string  `json:"logLevel" enum:"off,debug,info,warn" enumTitles:"Off,Debug,Info,Warn" default:"off" title:"Log level" description:"Write received messages to the module log with this level"`
 }
 
 type InMessage struct {
@@ -23,11 +49,91 @@ type InMessage struct {
 }
 
 type Control struct {
-	Context Context `json:"context" readonly:"true" required:"true" title:"Context"`
+	Context    Context      `json:"context" readonly:"true" required:"true" title:"Context"`
+	Clear      bool         `json:"clear" format:"button" title:"Clear" required:"true" description:"Clear captured message"`
+	ReceivedAt string       `json:"receivedAt,omitempty" readonly:"true" title:"Received at"`
+	TraceID    string       `json:"traceId,omitempty" readonly:"true" title:"Trace ID"`
+	Count      int          `json:"count" readonly:"true" title:"Messages" description:"Number of messages received"`
+	Rate       int          `json:"rate" readonly:"true" title:"Rate (per minute)" description:"Number of messages received within the last minute"`
+	Diff       *DiffSummary `json:"diff,omitempty" readonly:"true" title:"Diff" description:"Fields changed compared to the previous message"`
+}
+
+// DumpControl is shown instead of Control when dump port is enabled
+type DumpControl struct {
+	Context    Context      `json:"context" readonly:"true" required:"true" title:"Context"`
+	Clear      bool         `json:"clear" format:"button" title:"Clear" required:"true" description:"Clear captured message"`
+	Dump       bool         `json:"dump" format:"button" title:"Dump" required:"true" description:"Send captured messages to dump result port"`
+	ReceivedAt string       `json:"receivedAt,omitempty" readonly:"true" title:"Received at"`
+	TraceID    string       `json:"traceId,omitempty" readonly:"true" title:"Trace ID"`
+	Count      int          `json:"count" readonly:"true" title:"Messages" description:"Number of messages received"`
+	Rate       int          `json:"rate" readonly:"true" title:"Rate (per minute)" description:"Number of messages received within the last minute"`
+	Diff       *DiffSummary `json:"diff,omitempty" readonly:"true" title:"Diff" description:"Fields changed compared to the previous message"`
+}
+
+type DumpRequest struct {
+	Context Context `json:"context" configurable:"true" title:"Context" description:"Arbitrary message to be send together with the dump"`
+}
+
+type DumpEntry struct {
+	Context    Context `json:"context"`
+	ReceivedAt string  `json:"receivedAt"`
+	TraceID    string  `json:"traceId,omitempty"`
+}
+
+type DumpResult struct {
+	Context Context     `json:"context"`
+	Entries []DumpEntry `json:"entries"`
+	Count   int         `json:"count"`
+}
+
+// DiffSummary lists JSON paths of fields changed between two messages
+type DiffSummary struct {
+	Added   []string `json:"added" title:"Added"`
+	Removed []string `json:"removed" title:"Removed"`
+	Changed []string `json:"changed" title:"Changed"`
 }
 
 type Component struct {
 	settings Settings
+	// previous is decoded JSON of the previous message, kept only to show diff
+	previous    interface{}
+	hasPrevious bool
+	diff        *DiffSummary
+	receivedAt  time.Time
+	traceID     string
+	count       int
+	rate        rate
+
+	logger zerolog.Logger
+}
+
+// rate counts messages per second within the last minute
+type rate struct {
+	seconds [60]int64
+	counts  [60]int
+}
+
+func (r *rate) add(now time.Time) {
+	sec := now.Unix()
+	i := sec % 60
+	if r.seconds[i] != sec {
+		r.seconds[i] = sec
+		r.counts[i] = 0
+	}
+	r.counts[i]++
+}
+
+func (r *rate) perMinute(now time.Time) int {
+	var (
+		sec   = now.Unix()
+		total int
+	)
+	for i, s := range r.seconds {
+		if sec-s < 60 {
+			total += r.counts[i]
+		}
+	}
+	return total
 }
 
 func (t *Component) GetInfo() module.ComponentInfo {
@@ -47,21 +153,146 @@ func (t *Component) Handle(ctx context.Context, output module.Handler, port stri
 		if !ok {
 			return fmt.Errorf("invalid settings")
 		}
+		switch in.LogLevel {
+		case "", LogLevelOff, LogLevelDebug, LogLevelInfo, LogLevelWarn:
+		default:
+			return fmt.Errorf("unknown log level: %s", in.LogLevel)
+		}
 		t.settings = in
+		t.previous = nil
+		t.hasPrevious = false
+		t.diff = nil
 		return nil
 	case InPort:
 		if in, ok := msg.(InMessage); ok {
-			t.settings.Context = in.Context
+			if t.settings.ShowDiff {
+				current, err := normalize(in.Context)
+				if err != nil {
+					return err
+				}
+				// first message has nothing to be compared with
+				t.diff = nil
+				if t.hasPrevious {
+					t.diff = getDiff(t.previous, current)
+				}
+				// original message is not kept
+				t.previous = current
+				t.hasPrevious = true
+			}
+			display, err := t.display(in.Context)
+			if err != nil {
+				return err
+			}
+			t.settings.Context = display
+			t.receivedAt = time.Now()
+			t.count++
+			t.rate.add(t.receivedAt)
+			t.traceID = ""
+			if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+				t.traceID = sc.TraceID().String()
+			}
+			t.log(in.Context)
 			return output(ctx, module.ReconcilePort, nil)
 		}
 		return fmt.Errorf("invalid message in")
+	case module.ControlPort:
+		var clear bool
+		switch in := msg.(type) {
+		case Control:
+			clear = in.Clear
+		case DumpControl:
+			if in.Dump {
+				return t.dump(ctx, output, nil)
+			}
+			clear = in.Clear
+		default:
+			return fmt.Errorf("invalid control message")
+		}
+		if clear {
+			t.clear()
+			return output(ctx, module.ReconcilePort, nil)
+		}
+		return nil
+	case DumpPort:
+		in, ok := msg.(DumpRequest)
+		if !ok {
+			return fmt.Errorf("invalid dump request")
+		}
+		return t.dump(ctx, output, in.Context)
 	}
 
 	return fmt.Errorf("unknown port: %s", port)
 }
 
+func (t *Component) clear() {
+	t.settings.Context = nil
+	t.previous = nil
+	t.hasPrevious = false
+	t.diff = nil
+	t.receivedAt = time.Time{}
+	t.traceID = ""
+	t.count = 0
+	t.rate = rate{}
+}
+
+// dump sends captured message further
+func (t *Component) dump(ctx context.Context, output module.Handler, reqContext Context) error {
+	if !t.settings.EnableDumpPort {
+		return fmt.Errorf("dump port is disabled")
+	}
+
+	result := DumpResult{
+		Context: reqContext,
+		Entries: []DumpEntry{},
+	}
+	if !t.receivedAt.IsZero() {
+		result.Entries = append(result.Entries, DumpEntry{
+			Context:    t.settings.Context,
+			ReceivedAt: t.receivedAt.Format(time.RFC3339Nano),
+			TraceID:    t.traceID,
+		})
+	}
+	result.Count = len(result.Entries)
+
+	if t.settings.ClearAfterDump {
+		t.clear()
+		_ = output(ctx, module.ReconcilePort, nil)
+	}
+	return output(ctx, DumpResultPort, result)
+}
+
+func (t *Component) getControl() Control {
+	control := Control{
+		Context: t.settings.Context,
+		TraceID: t.traceID,
+		Count:   t.count,
+		Rate:    t.rate.perMinute(time.Now()),
+		Diff:    t.diff,
+	}
+	if !t.receivedAt.IsZero() {
+		control.ReceivedAt = t.receivedAt.Format(time.RFC3339Nano)
+	}
+	return control
+}
+
+// getPortControl returns control shown by control port, dump button is shown only if dump port is enabled
+func (t *Component) getPortControl() interface{} {
+	control := t.getControl()
+	if !t.settings.EnableDumpPort {
+		return control
+	}
+	return DumpControl{
+		Context:    control.Context,
+		ReceivedAt: control.ReceivedAt,
+		TraceID:    control.TraceID,
+		Count:      control.Count,
+		Rate:       control.Rate,
+		Diff:       control.Diff,
+	}
+}
+
 func (t *Component) Ports() []module.Port {
-	return []module.Port{
+	ports := []module.Port{
 		{
 			Name:          InPort,
 			Label:         "In",
@@ -70,11 +301,9 @@ func (t *Component) Ports() []module.Port {
 			Position:      module.Left,
 		},
 		{
-			Name:  module.ControlPort,
-			Label: "Control",
-			Configuration: Control{
-				Context: t.settings.Context,
-			},
+			Name:          module.ControlPort,
+			Label:         "Control",
+			Configuration: t.getPortControl(),
 		},
 		{
 			Name:          module.SettingsPort,
@@ -83,10 +312,144 @@ func (t *Component) Ports() []module.Port {
 			Configuration: t.settings,
 		},
 	}
+	if t.settings.EnableDumpPort {
+		ports = append(ports, module.Port{
+			Name:          DumpPort,
+			Label:         "Dump",
+			Source:        true,
+			Configuration: DumpRequest{},
+			Position:      module.Left,
+		}, module.Port{
+			Name:          DumpResultPort,
+			Label:         "Dump result",
+			Source:        false,
+			Configuration: DumpResult{},
+			Position:      module.Right,
+		})
+	}
+	return ports
+}
+
+// display returns message as it is shown in control, formatted JSON string if pretty print or size limit is on
+func (t *Component) display(msg Context) (Context, error) {
+	if !t.settings.PrettyPrint && t.settings.MaxDisplayBytes <= 0 {
+		return msg, nil
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if t.settings.PrettyPrint {
+		data, err = json.MarshalIndent(msg, "", "  ")
+	} else {
+		data, err = json.Marshal(msg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode message: %v", err)
+	}
+
+	return truncate(data, t.settings.MaxDisplayBytes), nil
+}
+
+// truncate cuts data longer than the limit, zero limit means no limit
+func truncate(data []byte, limit int) string {
+	if limit <= 0 || len(data) <= limit {
+		return string(data)
+	}
+	// do not cut multibyte characters
+	for limit > 0 && !utf8.RuneStart(data[limit]) {
+		limit--
+	}
+	return fmt.Sprintf("%s… (truncated, full size %d bytes)", data[:limit], len(data))
+}
+
+// log writes received message to the module log
+func (t *Component) log(msg Context) {
+	var event *zerolog.Event
+	switch t.settings.LogLevel {
+	case LogLevelDebug:
+		event = t.logger.Debug()
+	case LogLevelInfo:
+		event = t.logger.Info()
+	case LogLevelWarn:
+		event = t.logger.Warn()
+	default:
+		return
+	}
+
+	limit := t.settings.MaxDisplayBytes
+	if limit <= 0 {
+		limit = maxLogBytes
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		event.Err(err)
+	}
+	event.Str("component", ComponentName).
+		Str("payload", truncate(data, limit)).
+		Str("traceId", t.traceID).
+		Msg("message received")
+}
+
+// getDiff compares normalized messages field by field
+func getDiff(a, b interface{}) *DiffSummary {
+	diff := &DiffSummary{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []string{},
+	}
+	compare("$", a, b, diff)
+
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.Sort(diff.Changed)
+	return diff
+}
+
+// normalize converts message into decoded JSON so it does not share data with the original one
+func normalize(v Context) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode message: %v", err)
+	}
+	var result interface{}
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unable to decode message: %v", err)
+	}
+	return result, nil
+}
+
+// compare walks both objects recursively, anything other than object is compared as a whole
+func compare(path string, a, b interface{}, diff *DiffSummary) {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		if !reflect.DeepEqual(a, b) {
+			diff.Changed = append(diff.Changed, path)
+		}
+		return
+	}
+
+	for k, av := range am {
+		bv, ok := bm[k]
+		if !ok {
+			diff.Removed = append(diff.Removed, path+"."+k)
+			continue
+		}
+		compare(path+"."+k, av, bv, diff)
+	}
+	for k := range bm {
+		if _, ok := am[k]; !ok {
+			diff.Added = append(diff.Added, path+"."+k)
+		}
+	}
 }
 
 func (t *Component) Instance() module.Component {
-	return &Component{}
+	return &Component{
+		logger: log.Logger,
+	}
 }
 
 var _ module.Component = (*Component)(nil)
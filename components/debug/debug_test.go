@@ -0,0 +1,286 @@
+package debug
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"github.com/tiny-systems/module/module"
+	"go.opentelemetry.io/otel/trace"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComponent_Diff(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{ShowDiff: true})
+
+	reconcile := func(ctx context.Context, port string, data interface{}) error {
+		return nil
+	}
+	_ = c.Handle(context.Background(), reconcile, InPort, InMessage{
+		Context: map[string]interface{}{
+			"status": "UP",
+			"host":   map[string]interface{}{"name": "a"},
+			"old":    1,
+		},
+	})
+	if c.diff != nil {
+		t.Errorf("first message should have no diff, got %+v", c.diff)
+	}
+	err := c.Handle(context.Background(), reconcile, InPort, InMessage{
+		Context: map[string]interface{}{
+			"status": "DOWN",
+			"host":   map[string]interface{}{"name": "a", "port": 80},
+			"reason": "timeout",
+		},
+	})
+	if err != nil {
+		t.Fatalf("handle error: %v", err)
+	}
+
+	diff := c.diff
+	if diff == nil {
+		t.Fatal("diff is empty")
+	}
+	if want := []string{"$.host.port", "$.reason"}; !slices.Equal(diff.Added, want) {
+		t.Errorf("expected added %v, got %v", want, diff.Added)
+	}
+	if want := []string{"$.old"}; !slices.Equal(diff.Removed, want) {
+		t.Errorf("expected removed %v, got %v", want, diff.Removed)
+	}
+	if want := []string{"$.status"}; !slices.Equal(diff.Changed, want) {
+		t.Errorf("expected changed %v, got %v", want, diff.Changed)
+	}
+
+	// message after clear is the first one again
+	_ = c.Handle(context.Background(), reconcile, module.ControlPort, Control{Clear: true})
+	_ = c.Handle(context.Background(), reconcile, InPort, InMessage{Context: nil})
+	if c.diff != nil {
+		t.Errorf("first message after clear should have no diff, got %+v", c.diff)
+	}
+	_ = c.Handle(context.Background(), reconcile, InPort, InMessage{Context: "ctx"})
+	if c.diff == nil || !slices.Equal(c.diff.Changed, []string{"$"}) {
+		t.Errorf("nil previous message should be compared, got %+v", c.diff)
+	}
+
+	// only a copy of the previous message is kept
+	msg := map[string]interface{}{"n": 1}
+	_ = c.Handle(context.Background(), reconcile, InPort, InMessage{Context: msg})
+	msg["n"] = 2
+	_ = c.Handle(context.Background(), reconcile, InPort, InMessage{Context: msg})
+	if c.diff == nil || !slices.Equal(c.diff.Changed, []string{"$.n"}) {
+		t.Errorf("expected changed $.n after mutating the message, got %+v", c.diff)
+	}
+}
+
+func TestComponent_Trace(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	traceID, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanID, _ := trace.SpanIDFromHex("0102030405060708")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	before := time.Now()
+	_ = c.Handle(ctx, func(ctx context.Context, port string, data interface{}) error {
+		return nil
+	}, InPort, InMessage{Context: "ctx"})
+
+	control := c.getControl()
+	if control.TraceID != traceID.String() {
+		t.Errorf("expected trace id %s, got %q", traceID, control.TraceID)
+	}
+	receivedAt, err := time.Parse(time.RFC3339Nano, control.ReceivedAt)
+	if err != nil {
+		t.Fatalf("invalid received at: %v", err)
+	}
+	if receivedAt.Before(before) {
+		t.Errorf("unexpected received at: %v", receivedAt)
+	}
+}
+
+func TestComponent_Clear(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	var reconciled int
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == module.ReconcilePort {
+			reconciled++
+		}
+		return nil
+	}
+	_ = c.Handle(context.Background(), handler, InPort, InMessage{Context: "ctx"})
+	if err := c.Handle(context.Background(), handler, module.ControlPort, Control{Clear: true}); err != nil {
+		t.Fatalf("clear error: %v", err)
+	}
+
+	if control := c.getControl(); control.Context != nil || control.ReceivedAt != "" {
+		t.Errorf("control was not cleared: %+v", control)
+	}
+	if reconciled != 2 {
+		t.Errorf("expected reconcile after clear, got %d reconciles", reconciled)
+	}
+}
+
+func TestComponent_Count(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	reconcile := func(ctx context.Context, port string, data interface{}) error {
+		return nil
+	}
+	for i := 0; i < 5; i++ {
+		_ = c.Handle(context.Background(), reconcile, InPort, InMessage{Context: i})
+	}
+	if control := c.getControl(); control.Count != 5 || control.Rate != 5 {
+		t.Errorf("expected 5 messages, got count %d and rate %d", control.Count, control.Rate)
+	}
+
+	_ = c.Handle(context.Background(), reconcile, module.ControlPort, Control{Clear: true})
+	if control := c.getControl(); control.Count != 0 || control.Rate != 0 {
+		t.Errorf("expected counters to be cleared, got count %d and rate %d", control.Count, control.Rate)
+	}
+}
+
+func TestRate(t *testing.T) {
+	var (
+		r   rate
+		now = time.Unix(1000, 0)
+	)
+	r.add(now)
+	r.add(now.Add(30 * time.Second))
+	r.add(now.Add(30 * time.Second))
+
+	if n := r.perMinute(now.Add(45 * time.Second)); n != 3 {
+		t.Errorf("expected 3 messages within a minute, got %d", n)
+	}
+	if n := r.perMinute(now.Add(75 * time.Second)); n != 2 {
+		t.Errorf("expected old message to leave the window, got %d", n)
+	}
+	// same bucket a minute later
+	r.add(now.Add(60 * time.Second))
+	if n := r.perMinute(now.Add(60 * time.Second)); n != 3 {
+		t.Errorf("expected reused bucket to be reset, got %d", n)
+	}
+}
+
+func TestComponent_Display(t *testing.T) {
+	msg := map[string]interface{}{"name": "ёжик", "id": 1}
+
+	tests := []struct {
+		name     string
+		settings Settings
+		want     Context
+	}{
+		{name: "as is", want: msg},
+		{name: "pretty", settings: Settings{PrettyPrint: true}, want: "{\n  \"id\": 1,\n  \"name\": \"ёжик\"\n}"},
+		{name: "fits", settings: Settings{MaxDisplayBytes: 100}, want: `{"id":1,"name":"ёжик"}`},
+		{name: "truncated", settings: Settings{MaxDisplayBytes: 18}, want: `{"id":1,"name":"ё… (truncated, full size 26 bytes)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := (&Component{}).Instance().(*Component)
+			_ = c.Handle(context.Background(), nil, module.SettingsPort, tt.settings)
+			_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+				return nil
+			}, InPort, InMessage{Context: msg})
+
+			got := c.getControl().Context
+			if s, ok := tt.want.(string); ok {
+				if got != s {
+					t.Errorf("expected %q, got %q", s, got)
+				}
+				return
+			}
+			if _, ok := got.(map[string]interface{}); !ok {
+				t.Errorf("expected message to be shown as is, got %v", got)
+			}
+		})
+	}
+}
+
+func TestComponent_Log(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	var buf bytes.Buffer
+	c.logger = zerolog.New(&buf)
+
+	reconcile := func(ctx context.Context, port string, data interface{}) error {
+		return nil
+	}
+	_ = c.Handle(context.Background(), reconcile, InPort, InMessage{Context: "quiet"})
+	if buf.Len() != 0 {
+		t.Fatalf("nothing should be logged by default, got %s", buf.String())
+	}
+
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{LogLevel: LogLevelWarn, MaxDisplayBytes: 10})
+	_ = c.Handle(context.Background(), reconcile, InPort, InMessage{Context: strings.Repeat("a", 20)})
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid log entry %q: %v", buf.String(), err)
+	}
+	if entry["level"] != "warn" || entry["component"] != ComponentName {
+		t.Errorf("unexpected log entry: %v", entry)
+	}
+	if payload, _ := entry["payload"].(string); !strings.HasPrefix(payload, `"aaaaaaaaa… (truncated`) {
+		t.Errorf("payload should be truncated, got %q", payload)
+	}
+
+	if err := c.Handle(context.Background(), nil, module.SettingsPort, Settings{LogLevel: "trace"}); err == nil {
+		t.Error("expected error for unknown log level")
+	}
+}
+
+func TestComponent_Dump(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableDumpPort: true})
+
+	var results []DumpResult
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == DumpResultPort {
+			results = append(results, data.(DumpResult))
+		}
+		return nil
+	}
+	_ = c.Handle(context.Background(), handler, InPort, InMessage{Context: "ctx"})
+
+	if control, ok := c.getPortControl().(DumpControl); !ok || control.Context != "ctx" {
+		t.Errorf("expected dump control, got %+v", c.getPortControl())
+	}
+	if err := c.Handle(context.Background(), handler, module.ControlPort, DumpControl{Dump: true}); err != nil {
+		t.Fatalf("dump error: %v", err)
+	}
+	if err := c.Handle(context.Background(), handler, DumpPort, DumpRequest{Context: "req"}); err != nil {
+		t.Fatalf("dump error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 dumps, got %d", len(results))
+	}
+	for _, result := range results {
+		if result.Count != 1 || result.Entries[0].Context != "ctx" || result.Entries[0].ReceivedAt == "" {
+			t.Errorf("unexpected dump: %+v", result)
+		}
+	}
+	if results[1].Context != "req" {
+		t.Errorf("request context was not passed through: %+v", results[1])
+	}
+
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableDumpPort: true, ClearAfterDump: true})
+	_ = c.Handle(context.Background(), handler, InPort, InMessage{Context: "ctx"})
+	_ = c.Handle(context.Background(), handler, DumpPort, DumpRequest{})
+	_ = c.Handle(context.Background(), handler, DumpPort, DumpRequest{})
+	if len(results) != 4 || results[2].Count != 1 || results[3].Count != 0 {
+		t.Errorf("expected dump to be cleared, got %+v", results[2:])
+	}
+
+	// no dump button without dump port
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{})
+	if _, ok := c.getPortControl().(Control); !ok {
+		t.Errorf("expected control without dump button, got %T", c.getPortControl())
+	}
+}
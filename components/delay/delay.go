@@ -12,6 +12,7 @@ const (
 	ComponentName        = "delay"
 	OutPort       string = "out"
 	InPort        string = "in"
+	ErrorPort     string = "error"
 )
 
 type Context any
@@ -21,7 +22,32 @@ type Request struct {
 	Delay   int     `json:"delay" required:"true" title:"Component (ms)"`
 }
 
+type Settings struct {
+	EnableErrorPort bool `json:"enableErrorPort" title:"Enable error port" description:"Error port receives a message when the delay is cancelled"`
+	AllowZeroDelay  bool `json:"allowZeroDelay" title:"Allow zero delay" description:"Messages with zero delay are passed further immediately instead of being rejected"`
+	MinDelayMs      int  `json:"minDelayMs" title:"Min delay (ms)" description:"Shorter delays are increased to this value. When min or max delay is set out port receives requested and applied delay together with the context" minimum:"0" default:"0"`
+	MaxDelayMs      int  `json:"maxDelayMs" title:"Max delay (ms)" description:"Longer delays are reduced to this value. Zero means no limit" minimum:"0" default:"0"`
+}
+
+// clamp reports if the delay is limited by settings
+func (s Settings) clamp() bool {
+	return s.MinDelayMs > 0 || s.MaxDelayMs > 0
+}
+
+// OutMessage is sent instead of the bare context when delay is clamped
+type OutMessage struct {
+	Context          Context `json:"context"`
+	RequestedDelayMs int     `json:"requestedDelayMs" title:"Requested delay (ms)"`
+	DelayMs          int     `json:"delayMs" title:"Delay (ms)" description:"Delay applied after clamping"`
+}
+
+type CancellationEvent struct {
+	Context          Context `json:"context"`
+	CancelledAfterMs int64   `json:"cancelledAfterMs" title:"Cancelled after (ms)"`
+}
+
 type Component struct {
+	settings Settings
 }
 
 func (t *Component) Instance() module.Component {
@@ -38,22 +64,81 @@ func (t *Component) GetInfo() module.ComponentInfo {
 }
 
 func (t *Component) Handle(ctx context.Context, handler module.Handler, port string, msg interface{}) error {
+	if port == module.SettingsPort {
+		in, ok := msg.(Settings)
+		if !ok {
+			return fmt.Errorf("invalid settings")
+		}
+		if in.MinDelayMs < 0 || in.MaxDelayMs < 0 {
+			return fmt.Errorf("min and max delay can not be negative")
+		}
+		if in.MaxDelayMs > 0 && in.MaxDelayMs < in.MinDelayMs {
+			return fmt.Errorf("max delay can not be less than min delay")
+		}
+		t.settings = in
+		return nil
+	}
 
 	in, ok := msg.(Request)
 	if !ok {
 		return fmt.Errorf("invalid message")
 	}
-	if in.Delay <= 0 {
+	if in.Delay < 0 || in.Delay == 0 && !t.settings.AllowZeroDelay {
 		return fmt.Errorf("invalid delay")
 	}
 
-	time.Sleep(time.Millisecond * time.Duration(in.Delay))
-	_ = handler(ctx, OutPort, in.Context)
+	delay := max(in.Delay, t.settings.MinDelayMs)
+	if t.settings.MaxDelayMs > 0 {
+		delay = min(delay, t.settings.MaxDelayMs)
+	}
+
+	var out interface{} = in.Context
+	if t.settings.clamp() {
+		out = OutMessage{
+			Context:          in.Context,
+			RequestedDelayMs: in.Delay,
+			DelayMs:          delay,
+		}
+	}
+	if delay == 0 {
+		_ = handler(ctx, OutPort, out)
+		return nil
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(time.Millisecond * time.Duration(delay))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		if t.settings.EnableErrorPort {
+			// context is already cancelled, error port should still receive the event
+			_ = handler(context.WithoutCancel(ctx), ErrorPort, CancellationEvent{
+				Context:          in.Context,
+				CancelledAfterMs: time.Since(start).Milliseconds(),
+			})
+		}
+		return ctx.Err()
+	}
+
+	_ = handler(ctx, OutPort, out)
 	return nil
 }
 
 func (t *Component) Ports() []module.Port {
-	return []module.Port{
+	var out interface{} = new(Context)
+	if t.settings.clamp() {
+		out = OutMessage{}
+	}
+
+	ports := []module.Port{
+		{
+			Name:          module.SettingsPort,
+			Label:         "Settings",
+			Source:        true,
+			Configuration: t.settings,
+		},
 		{
 			Name:   InPort,
 			Label:  "In",
@@ -67,10 +152,20 @@ func (t *Component) Ports() []module.Port {
 			Name:          OutPort,
 			Label:         "Out",
 			Source:        false,
-			Configuration: new(Context),
+			Configuration: out,
 			Position:      module.Right,
 		},
 	}
+	if t.settings.EnableErrorPort {
+		ports = append(ports, module.Port{
+			Name:          ErrorPort,
+			Label:         "Error",
+			Source:        false,
+			Configuration: CancellationEvent{},
+			Position:      module.Bottom,
+		})
+	}
+	return ports
 }
 
 var _ module.Component = (*Component)(nil)
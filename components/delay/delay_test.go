@@ -0,0 +1,122 @@
+package delay
+
+import (
+	"context"
+	"errors"
+	"github.com/tiny-systems/module/module"
+	"testing"
+	"time"
+)
+
+func TestComponent_Cancel(t *testing.T) {
+	c := (&Component{}).Instance()
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableErrorPort: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var (
+		ports []string
+		event CancellationEvent
+	)
+	start := time.Now()
+	err := c.Handle(ctx, func(ctx context.Context, port string, data interface{}) error {
+		ports = append(ports, port)
+		if port == ErrorPort {
+			event = data.(CancellationEvent)
+		}
+		return nil
+	}, InPort, Request{Context: "ctx", Delay: 5000})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("delay was not cancelled")
+	}
+	if len(ports) != 1 || ports[0] != ErrorPort {
+		t.Fatalf("expected only error port to receive message, got %v", ports)
+	}
+	if event.Context != "ctx" || event.CancelledAfterMs < 10 {
+		t.Errorf("unexpected cancellation event: %+v", event)
+	}
+}
+
+func TestComponent_Delay(t *testing.T) {
+	c := (&Component{}).Instance()
+
+	var out interface{}
+	err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			out = data
+		}
+		return nil
+	}, InPort, Request{Context: "ctx", Delay: 10})
+	if err != nil {
+		t.Fatalf("delay error: %v", err)
+	}
+	if out != "ctx" {
+		t.Errorf("expected context to be sent, got %v", out)
+	}
+}
+
+func TestComponent_ZeroDelay(t *testing.T) {
+	c := (&Component{}).Instance()
+
+	var out interface{}
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		out = data
+		return nil
+	}
+	if err := c.Handle(context.Background(), handler, InPort, Request{Context: "ctx"}); err == nil {
+		t.Error("expected error for zero delay")
+	}
+
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{AllowZeroDelay: true})
+	if err := c.Handle(context.Background(), handler, InPort, Request{Context: "ctx"}); err != nil {
+		t.Fatalf("delay error: %v", err)
+	}
+	if out != "ctx" {
+		t.Errorf("expected context to pass through, got %v", out)
+	}
+	if err := c.Handle(context.Background(), handler, InPort, Request{Context: "ctx", Delay: -1}); err == nil {
+		t.Error("expected error for negative delay")
+	}
+}
+
+func TestComponent_Clamp(t *testing.T) {
+	c := (&Component{}).Instance()
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{MinDelayMs: 20, MaxDelayMs: 50})
+
+	tests := []struct {
+		delay int
+		want  int
+	}{
+		{delay: 5, want: 20},
+		{delay: 30, want: 30},
+		{delay: 5000, want: 50},
+	}
+	for _, tt := range tests {
+		var out OutMessage
+		start := time.Now()
+		err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+			out = data.(OutMessage)
+			return nil
+		}, InPort, Request{Context: "ctx", Delay: tt.delay})
+		if err != nil {
+			t.Fatalf("delay error: %v", err)
+		}
+		elapsed := time.Since(start)
+
+		if out.DelayMs != tt.want || out.RequestedDelayMs != tt.delay || out.Context != "ctx" {
+			t.Errorf("unexpected out message: %+v", out)
+		}
+		if elapsed < time.Duration(tt.want)*time.Millisecond || elapsed > time.Second {
+			t.Errorf("delay %d should take %dms, took %v", tt.delay, tt.want, elapsed)
+		}
+	}
+
+	if err := c.Handle(context.Background(), nil, module.SettingsPort, Settings{MinDelayMs: 50, MaxDelayMs: 20}); err == nil {
+		t.Error("expected error for max delay less than min delay")
+	}
+}
@@ -0,0 +1,132 @@
+package eventemitter
+
+import (
+	"context"
+	"fmt"
+	"github.com/tiny-systems/module/module"
+	"github.com/tiny-systems/module/registry"
+	"strings"
+)
+
+const (
+	ComponentName        = "event_emitter"
+	PublishPort   string = "publish"
+)
+
+type Context any
+
+type Settings struct {
+	Topics    []string `json:"topics" required:"true" title:"Topics" minItems:"1" uniqueItems:"true" description:"Each topic gets its own output port"`
+	Broadcast bool     `json:"broadcast" title:"Broadcast" description:"Send every published message to all topics regardless of its topic"`
+}
+
+type PublishMessage struct {
+	Topic   string  `json:"topic" required:"true" title:"Topic"`
+	Context Context `json:"context" configurable:"true" title:"Context" description:"Arbitrary message to be published"`
+}
+
+type Component struct {
+	settings Settings
+}
+
+var defaultSettings = Settings{
+	Topics: []string{"A", "B"},
+}
+
+func (t *Component) Instance() module.Component {
+	return &Component{
+		settings: defaultSettings,
+	}
+}
+
+func (t *Component) GetInfo() module.ComponentInfo {
+	return module.ComponentInfo{
+		Name:        ComponentName,
+		Description: "Event emitter",
+		Info:        "Publishes incoming messages to output port of their topic. In broadcast mode sends every message to all topics.",
+		Tags:        []string{"SDK"},
+	}
+}
+
+func (t *Component) Handle(ctx context.Context, handler module.Handler, port string, msg interface{}) error {
+	switch port {
+	case module.SettingsPort:
+		in, ok := msg.(Settings)
+		if !ok {
+			return fmt.Errorf("invalid settings")
+		}
+		t.settings = in
+		return nil
+
+	case PublishPort:
+		in, ok := msg.(PublishMessage)
+		if !ok {
+			return fmt.Errorf("invalid message")
+		}
+
+		if t.settings.Broadcast {
+			for _, topic := range t.settings.Topics {
+				if err := handler(ctx, getPortNameFromTopic(topic), in.Context); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for _, topic := range t.settings.Topics {
+			if strings.EqualFold(topic, in.Topic) {
+				return handler(ctx, getPortNameFromTopic(topic), in.Context)
+			}
+		}
+		return fmt.Errorf("unknown topic: %s", in.Topic)
+	}
+
+	return fmt.Errorf("invalid port: %s", port)
+}
+
+// Ports generates output port for each topic
+func (t *Component) Ports() []module.Port {
+
+	topic := "A"
+	if len(t.settings.Topics) > 0 {
+		topic = t.settings.Topics[0]
+	}
+
+	ports := []module.Port{
+		{
+			Name:          module.SettingsPort,
+			Label:         "Settings",
+			Source:        true,
+			Configuration: t.settings,
+		},
+		{
+			Position: module.Left,
+			Name:     PublishPort,
+			Label:    "Publish",
+			Source:   true,
+			Configuration: PublishMessage{
+				Topic: topic,
+			},
+		},
+	}
+	for _, topic := range t.settings.Topics {
+		ports = append(ports, module.Port{
+			Position:      module.Right,
+			Name:          getPortNameFromTopic(topic),
+			Label:         strings.ToTitle(topic),
+			Source:        false,
+			Configuration: new(Context),
+		})
+	}
+	return ports
+}
+
+func getPortNameFromTopic(topic string) string {
+	return fmt.Sprintf("topic_%s", strings.ToLower(topic))
+}
+
+var _ module.Component = (*Component)(nil)
+
+func init() {
+	registry.Register(&Component{})
+}
@@ -0,0 +1,55 @@
+package eventemitter
+
+import (
+	"context"
+	"github.com/tiny-systems/module/module"
+	"reflect"
+	"testing"
+)
+
+func TestComponent_Publish(t *testing.T) {
+	tests := []struct {
+		name      string
+		broadcast bool
+		topic     string
+		want      []string
+	}{
+		{
+			name:  "topic a",
+			topic: "a",
+			want:  []string{"topic_a"},
+		},
+		{
+			name:  "topic b",
+			topic: "b",
+			want:  []string{"topic_b"},
+		},
+		{
+			name:      "broadcast",
+			broadcast: true,
+			topic:     "a",
+			want:      []string{"topic_a", "topic_b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := (&Component{}).Instance()
+			_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+				Topics:    []string{"a", "b"},
+				Broadcast: tt.broadcast,
+			})
+
+			var got []string
+			err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+				got = append(got, port)
+				return nil
+			}, PublishPort, PublishMessage{Topic: tt.topic, Context: "event"})
+			if err != nil {
+				t.Fatalf("publish error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got ports %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
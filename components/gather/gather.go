@@ -0,0 +1,203 @@
+package gather
+
+import (
+	"context"
+	"fmt"
+	"github.com/tiny-systems/module/module"
+	"github.com/tiny-systems/module/registry"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+)
+
+const (
+	ComponentName        = "gather"
+	OutPort       string = "out"
+	InPort        string = "in"
+)
+
+// finishedTTL is how long late results of a finished group are recognised
+const finishedTTL = 10 * time.Minute
+
+type Result any
+
+type Settings struct {
+	TimeoutMs int `json:"timeoutMs" title:"Timeout (ms)" description:"Emit partial results if not all items arrived in time. Zero waits forever." minimum:"0" default:"0"`
+}
+
+type InMessage struct {
+	ScatterID string `json:"scatterID" required:"true" title:"Scatter ID" description:"ID assigned by the Scatter component"`
+	Total     int    `json:"total" required:"true" title:"Total" description:"Number of items expected for this scatter ID"`
+	Result    Result `json:"result" configurable:"true" title:"Result" description:"Item result to be collected"`
+}
+
+type GatherResult struct {
+	ScatterID string   `json:"scatterID"`
+	Results   []Result `json:"results"`
+	Partial   bool     `json:"partial"`
+}
+
+type group struct {
+	results []Result
+	timer   *time.Timer
+}
+
+type Component struct {
+	settings Settings
+
+	groups map[string]*group
+	// finished remembers recently completed (true) or timed out (false) groups
+	finished   map[string]bool
+	groupsLock *sync.Mutex
+}
+
+func (t *Component) Instance() module.Component {
+	return &Component{
+		groups:     make(map[string]*group),
+		finished:   make(map[string]bool),
+		groupsLock: &sync.Mutex{},
+	}
+}
+
+func (t *Component) GetInfo() module.ComponentInfo {
+	return module.ComponentInfo{
+		Name:        ComponentName,
+		Description: "Gather",
+		Info:        "Collects messages with the same scatter ID and sends them further as a single message once all of them arrived.",
+		Tags:        []string{"SDK", "ARRAY"},
+	}
+}
+
+func (t *Component) Handle(ctx context.Context, handler module.Handler, port string, msg interface{}) error {
+
+	switch port {
+	case module.SettingsPort:
+		in, ok := msg.(Settings)
+		if !ok {
+			return fmt.Errorf("invalid settings")
+		}
+		t.settings = in
+		return nil
+
+	case InPort:
+		in, ok := msg.(InMessage)
+		if !ok {
+			return fmt.Errorf("invalid message")
+		}
+		if in.ScatterID == "" {
+			return fmt.Errorf("scatter ID is empty")
+		}
+		if in.Total <= 0 {
+			return fmt.Errorf("invalid total: %d", in.Total)
+		}
+
+		results, done, err := t.collect(in, func() {
+			// deadline reached, send whatever we have
+			if results := t.remove(in.ScatterID); results != nil {
+				_ = handler(trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx)), OutPort, GatherResult{
+					ScatterID: in.ScatterID,
+					Results:   results,
+					Partial:   true,
+				})
+			}
+		})
+		if err != nil || !done {
+			return err
+		}
+		return handler(ctx, OutPort, GatherResult{
+			ScatterID: in.ScatterID,
+			Results:   results,
+		})
+	}
+
+	return fmt.Errorf("invalid port: %s", port)
+}
+
+// collect adds result to its group, returns all results when the group is complete.
+// Results arriving after the group timed out are dropped, results beyond total are rejected
+func (t *Component) collect(in InMessage, onTimeout func()) ([]Result, bool, error) {
+	t.groupsLock.Lock()
+	defer t.groupsLock.Unlock()
+
+	if completed, ok := t.finished[in.ScatterID]; ok {
+		if completed {
+			return nil, false, fmt.Errorf("scatter %s already has all %d results", in.ScatterID, in.Total)
+		}
+		// partial result was sent already
+		return nil, false, nil
+	}
+
+	g, ok := t.groups[in.ScatterID]
+	if !ok {
+		g = &group{}
+		if t.settings.TimeoutMs > 0 {
+			g.timer = time.AfterFunc(time.Duration(t.settings.TimeoutMs)*time.Millisecond, onTimeout)
+		}
+		t.groups[in.ScatterID] = g
+	}
+
+	g.results = append(g.results, in.Result)
+	if len(g.results) < in.Total {
+		return nil, false, nil
+	}
+
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	t.finish(in.ScatterID, true)
+	return g.results, true, nil
+}
+
+// finish forgets the group and remembers it for a while so late results do not start a new one, callers hold the lock
+func (t *Component) finish(id string, completed bool) {
+	delete(t.groups, id)
+	t.finished[id] = completed
+	time.AfterFunc(finishedTTL, func() {
+		t.groupsLock.Lock()
+		defer t.groupsLock.Unlock()
+		delete(t.finished, id)
+	})
+}
+
+func (t *Component) remove(id string) []Result {
+	t.groupsLock.Lock()
+	defer t.groupsLock.Unlock()
+
+	g, ok := t.groups[id]
+	if !ok {
+		return nil
+	}
+	t.finish(id, false)
+	return g.results
+}
+
+func (t *Component) Ports() []module.Port {
+	return []module.Port{
+		{
+			Name:          module.SettingsPort,
+			Label:         "Settings",
+			Source:        true,
+			Configuration: t.settings,
+		},
+		{
+			Name:          InPort,
+			Label:         "In",
+			Source:        true,
+			Configuration: InMessage{},
+			Position:      module.Left,
+		},
+		{
+			Name:          OutPort,
+			Label:         "Out",
+			Source:        false,
+			Configuration: GatherResult{},
+			Position:      module.Right,
+		},
+	}
+}
+
+var _ module.Component = (*Component)(nil)
+
+func init() {
+	registry.Register(&Component{})
+}
@@ -0,0 +1,144 @@
+package gather
+
+import (
+	"context"
+	"github.com/tiny-systems/common-module/components/delay"
+	"github.com/tiny-systems/common-module/components/scatter"
+	"github.com/tiny-systems/module/module"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScatterGather(t *testing.T) {
+	g := (&Component{}).Instance().(*Component)
+	d := (&delay.Component{}).Instance()
+	s := (&scatter.Component{}).Instance()
+
+	var (
+		results []GatherResult
+		wg      sync.WaitGroup
+	)
+
+	gatherOut := func(ctx context.Context, port string, data interface{}) error {
+		if port != OutPort {
+			t.Fatalf("invalid output port: %v", port)
+		}
+		results = append(results, data.(GatherResult))
+		return nil
+	}
+
+	scatterOut := func(ctx context.Context, port string, data interface{}) error {
+		item := data.(scatter.OutMessage)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = d.Handle(ctx, func(ctx context.Context, port string, data interface{}) error {
+				return g.Handle(ctx, gatherOut, InPort, InMessage{
+					ScatterID: item.ScatterID,
+					Total:     item.Total,
+					Result:    data,
+				})
+			}, delay.InPort, delay.Request{Context: item.Item, Delay: 10 * (5 - item.Index)})
+		}()
+		return nil
+	}
+
+	if err := s.Handle(context.Background(), scatterOut, scatter.InPort, scatter.InMessage{
+		Array: []scatter.ItemContext{1, 2, 3, 4, 5},
+	}); err != nil {
+		t.Fatalf("scatter error: %v", err)
+	}
+	wg.Wait()
+
+	if len(results) != 1 {
+		t.Fatalf("expected exactly one gathered result, got %d", len(results))
+	}
+	if results[0].Partial {
+		t.Errorf("result should not be partial")
+	}
+
+	var got []int
+	for _, r := range results[0].Results {
+		got = append(got, r.(int))
+	}
+	sort.Ints(got)
+	if len(got) != 5 {
+		t.Fatalf("expected 5 results, got %v", got)
+	}
+	for i, v := range got {
+		if v != i+1 {
+			t.Errorf("unexpected results: %v", got)
+			break
+		}
+	}
+}
+
+func TestGatherTimeout(t *testing.T) {
+	g := (&Component{}).Instance().(*Component)
+	_ = g.Handle(context.Background(), nil, module.SettingsPort, Settings{TimeoutMs: 50})
+
+	partial := make(chan GatherResult, 1)
+	out := func(ctx context.Context, port string, data interface{}) error {
+		partial <- data.(GatherResult)
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := g.Handle(context.Background(), out, InPort, InMessage{ScatterID: "id", Total: 3, Result: i}); err != nil {
+			t.Fatalf("gather error: %v", err)
+		}
+	}
+
+	select {
+	case r := <-partial:
+		if !r.Partial || len(r.Results) != 2 {
+			t.Errorf("expected partial result with 2 items, got %+v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("partial result was not emitted")
+	}
+}
+
+func TestGatherLateResults(t *testing.T) {
+	g := (&Component{}).Instance().(*Component)
+	_ = g.Handle(context.Background(), nil, module.SettingsPort, Settings{TimeoutMs: 20})
+
+	results := make(chan GatherResult, 4)
+	out := func(ctx context.Context, port string, data interface{}) error {
+		results <- data.(GatherResult)
+		return nil
+	}
+
+	_ = g.Handle(context.Background(), out, InPort, InMessage{ScatterID: "timeout", Total: 2, Result: 1})
+	if r := <-results; !r.Partial {
+		t.Fatalf("expected partial result, got %+v", r)
+	}
+	// late result is dropped instead of starting a new group
+	if err := g.Handle(context.Background(), out, InPort, InMessage{ScatterID: "timeout", Total: 2, Result: 2}); err != nil {
+		t.Fatalf("late result error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_ = g.Handle(context.Background(), out, InPort, InMessage{ScatterID: "complete", Total: 2, Result: i})
+	}
+	if r := <-results; r.Partial || len(r.Results) != 2 {
+		t.Fatalf("expected complete result, got %+v", r)
+	}
+	if err := g.Handle(context.Background(), out, InPort, InMessage{ScatterID: "complete", Total: 2, Result: 3}); err == nil {
+		t.Error("expected result beyond total to be rejected")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case r := <-results:
+		t.Errorf("unexpected result: %+v", r)
+	default:
+	}
+	g.groupsLock.Lock()
+	defer g.groupsLock.Unlock()
+	if len(g.groups) != 0 {
+		t.Errorf("late results left %d groups", len(g.groups))
+	}
+}
@@ -0,0 +1,158 @@
+package htmltemplate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/tiny-systems/module/module"
+	"github.com/tiny-systems/module/registry"
+	"html/template"
+)
+
+const (
+	ComponentName        = "html_template"
+	InPort        string = "in"
+	OutPort       string = "out"
+	ErrorPort     string = "error"
+)
+
+type Context any
+
+type Settings struct {
+	Template string            `json:"template" required:"true" title:"Template" format:"textarea" description:"HTML template, see Go html/template syntax. Request data is available as the dot object."`
+	Partials map[string]string `json:"partials,omitempty" title:"Partials" description:"Named sub-templates which can be used from the template with {{template \"name\" .}}"`
+}
+
+type RenderRequest struct {
+	Context Context        `json:"context" configurable:"true" title:"Context" description:"Arbitrary message to be send further"`
+	Data    map[string]any `json:"data" configurable:"true" title:"Data" description:"Template data"`
+}
+
+type RenderResult struct {
+	Context Context `json:"context"`
+	Output  string  `json:"output"`
+	Error   string  `json:"error,omitempty"`
+}
+
+type Component struct {
+	settings Settings
+	tmpl     *template.Template
+}
+
+func (t *Component) Instance() module.Component {
+	settings := Settings{
+		Template: "<p>{{.name}}</p>",
+	}
+	// parsed once so renders only read the template
+	tmpl, _ := parse(settings)
+	return &Component{
+		settings: settings,
+		tmpl:     tmpl,
+	}
+}
+
+func (t *Component) GetInfo() module.ComponentInfo {
+	return module.ComponentInfo{
+		Name:        ComponentName,
+		Description: "HTML Template",
+		Info:        "Renders incoming data using HTML template. Output is HTML-escaped.",
+		Tags:        []string{"SDK", "HTML"},
+	}
+}
+
+func (t *Component) Handle(ctx context.Context, handler module.Handler, port string, msg interface{}) error {
+
+	switch port {
+	case module.SettingsPort:
+		in, ok := msg.(Settings)
+		if !ok {
+			return fmt.Errorf("invalid settings")
+		}
+		tmpl, err := parse(in)
+		if err != nil {
+			return err
+		}
+		t.settings = in
+		t.tmpl = tmpl
+		return nil
+
+	case InPort:
+		in, ok := msg.(RenderRequest)
+		if !ok {
+			return fmt.Errorf("invalid message")
+		}
+		tmpl := t.tmpl
+		if tmpl == nil {
+			// component was not created by Instance, parse without caching
+			var err error
+			if tmpl, err = parse(t.settings); err != nil {
+				return err
+			}
+		}
+
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, in.Data); err != nil {
+			return handler(ctx, ErrorPort, RenderResult{
+				Context: in.Context,
+				Error:   err.Error(),
+			})
+		}
+		return handler(ctx, OutPort, RenderResult{
+			Context: in.Context,
+			Output:  buf.String(),
+		})
+	}
+
+	return fmt.Errorf("invalid port: %s", port)
+}
+
+func parse(s Settings) (*template.Template, error) {
+	tmpl := template.New(ComponentName)
+	for name, body := range s.Partials {
+		if _, err := tmpl.New(name).Parse(body); err != nil {
+			return nil, fmt.Errorf("unable to parse partial %s: %v", name, err)
+		}
+	}
+	if _, err := tmpl.Parse(s.Template); err != nil {
+		return nil, fmt.Errorf("unable to parse template: %v", err)
+	}
+	return tmpl, nil
+}
+
+func (t *Component) Ports() []module.Port {
+	return []module.Port{
+		{
+			Name:          module.SettingsPort,
+			Label:         "Settings",
+			Source:        true,
+			Configuration: t.settings,
+		},
+		{
+			Name:          InPort,
+			Label:         "In",
+			Source:        true,
+			Configuration: RenderRequest{},
+			Position:      module.Left,
+		},
+		{
+			Name:          OutPort,
+			Label:         "Out",
+			Source:        false,
+			Configuration: RenderResult{},
+			Position:      module.Right,
+		},
+		{
+			Name:          ErrorPort,
+			Label:         "Error",
+			Source:        false,
+			Configuration: RenderResult{},
+			Position:      module.Bottom,
+		},
+	}
+}
+
+var _ module.Component = (*Component)(nil)
+
+func init() {
+	registry.Register(&Component{})
+}
@@ -0,0 +1,95 @@
+package htmltemplate
+
+import (
+	"context"
+	"github.com/tiny-systems/module/module"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestComponent_Handle(t *testing.T) {
+	c := (&Component{}).Instance()
+
+	err := c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Template: `<table>{{range .rows}}{{template "row" .}}{{end}}</table>`,
+		Partials: map[string]string{
+			"row": `<tr><td>{{.name}}</td></tr>`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+
+	var result RenderResult
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port != OutPort {
+			t.Fatalf("unexpected port: %s", port)
+		}
+		result = data.(RenderResult)
+		return nil
+	}
+
+	err = c.Handle(context.Background(), handler, InPort, RenderRequest{
+		Context: 42,
+		Data: map[string]any{
+			"rows": []map[string]any{
+				{"name": "one"},
+				{"name": "two"},
+				{"name": "<script>"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+
+	if n := strings.Count(result.Output, "<tr>"); n != 3 {
+		t.Errorf("expected 3 rows, got %d: %s", n, result.Output)
+	}
+	if strings.Contains(result.Output, "<script>") {
+		t.Errorf("output is not escaped: %s", result.Output)
+	}
+	if result.Context != 42 {
+		t.Errorf("context was not passed through")
+	}
+}
+
+func TestComponent_ExecutionError(t *testing.T) {
+	c := (&Component{}).Instance()
+
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Template: `{{template "missing" .}}`,
+	})
+
+	var port string
+	_ = c.Handle(context.Background(), func(ctx context.Context, p string, data interface{}) error {
+		port = p
+		return nil
+	}, InPort, RenderRequest{})
+
+	if port != ErrorPort {
+		t.Errorf("expected error port, got %q", port)
+	}
+}
+
+func TestComponent_ConcurrentDefault(t *testing.T) {
+	c := (&Component{}).Instance()
+
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if result := data.(RenderResult); result.Output != "<p>x</p>" {
+			t.Errorf("unexpected output: %+v", result)
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = c.Handle(context.Background(), handler, InPort, RenderRequest{Data: map[string]any{"name": "x"}})
+		}()
+	}
+	wg.Wait()
+}
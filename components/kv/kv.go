@@ -3,12 +3,20 @@ package kv
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	cmap "github.com/orcaman/concurrent-map/v2"
 	"github.com/spyzhov/ajson"
 	"github.com/swaggest/jsonschema-go"
 	"github.com/tiny-systems/module/module"
 	"github.com/tiny-systems/module/registry"
+	"maps"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type KeyValueQueryRequestContext any
@@ -17,13 +25,50 @@ type KeyValueStoreRequestContext any
 const (
 	OpStore   = "store"
 	OptDelete = "delete"
+	OpInsert  = "insert"
+	OpUpdate  = "update"
+
+	OpIncrement = "increment"
+	OpDecrement = "decrement"
+)
+
+const (
+	QueryModeJSONPath = "jsonpath"
+	QueryModePrefix   = "prefix"
 )
 
 const (
-	PortStore       = "store"
-	PortQuery       = "query"
-	PortQueryResult = "query_result"
-	PortStoreAck    = "store_ack"
+	PortStore           = "store"
+	PortQuery           = "query"
+	PortQueryResult     = "query_result"
+	PortStoreAck        = "store_ack"
+	PortHistory         = "history"
+	PortHistoryResult   = "history_result"
+	PortAggregate       = "aggregate"
+	PortAggregateResult = "aggregate_result"
+	PortError           = "error"
+	PortEvicted         = "evicted"
+	PortExport          = "export"
+	PortExportResult    = "export_result"
+	PortImport          = "import"
+	PortChangeStream    = "change_stream"
+)
+
+const (
+	ImportReplace = "replace"
+	ImportMerge   = "merge"
+)
+
+const (
+	EvictionError = "error"
+	EvictionLRU   = "lru"
+)
+
+const (
+	AggSum = "sum"
+	AggAvg = "avg"
+	AggMin = "min"
+	AggMax = "max"
 )
 
 type KeyValueStoreDocument map[string]interface{}
@@ -44,33 +89,149 @@ type KeyValueStoreSettings struct {
 	Document           KeyValueStoreDocument `json:"document,omitempty" type:"object" required:"true" title:"Document" description:"Structure of the object will be used to store incoming messages. Values are arbitrary. Make sure the document has primary key defined below." configurable:"true"`
 	PrimaryKey         string                `json:"primaryKey" title:"Primary key" required:"true" default:"id"`
 	EnableStoreAckPort bool                  `json:"enableStoreResultPort" required:"true" title:"Enable Store Ack Port" default:"false" description:"Emits information if message was stored or not"`
+	EnableHistory      bool                  `json:"enableHistory" title:"Enable history" description:"Keeps track of operations made with each record. Adds history port."`
+	EnableErrorPort    bool                  `json:"enableErrorPort" title:"Enable error port" description:"Conflicts of insert and update operations are sent to the error port instead of failing"`
+	MaxRecords         int                   `json:"maxRecords" title:"Max records" description:"Maximum number of records kept in the store. Zero means unlimited." minimum:"0" default:"0"`
+	EvictionPolicy     string                `json:"evictionPolicy" enum:"error,lru" enumTitles:"Error,Least recently used" default:"error" title:"Eviction policy" description:"What to do when store is full: fail to store a new record or evict the least recently accessed one"`
+	EnableEvictedPort  bool                  `json:"enableEvictedPort" title:"Enable evicted port" description:"Sends evicted records further"`
+	EnableChangeStream bool                  `json:"enableChangeStream" title:"Enable change stream" description:"Sends an event after each change of the stored records"`
+	Indexes            []IndexDef            `json:"indexes,omitempty" title:"Indexes" description:"Fields to index. Queries with equality predicates on indexed fields skip the full scan."`
+	MaxHistoryPerKey   int                   `json:"maxHistoryPerKey" title:"Max history per key" description:"Maximum number of history entries kept per record. Zero means unlimited." minimum:"0" default:"100"`
+}
+
+type IndexDef struct {
+	Field string `json:"field" required:"true" title:"Field"`
 }
 
 type KeyValueStore struct {
-	records  cmap.ConcurrentMap[string, []byte]
+	// lock is held exclusively by snapshot export and import
+	lock *sync.RWMutex
+	// writeLock serializes changes so capacity check, eviction and insert happen at once
+	writeLock *sync.Mutex
+	records   cmap.ConcurrentMap[string, []byte]
+	accessed  cmap.ConcurrentMap[string, time.Time]
+	// indexes maps field=value to the set of primary keys
+	indexes  cmap.ConcurrentMap[string, cmap.ConcurrentMap[string, bool]]
+	history  cmap.ConcurrentMap[string, []HistoryEntry]
 	settings KeyValueStoreSettings
 }
 
-type KeyValueQueryRequest struct {
+type HistoryEntry struct {
+	Operation string                `json:"operation"`
+	Document  KeyValueStoreDocument `json:"document"`
+	At        time.Time             `json:"at"`
+}
+
+type HistoryRequest struct {
 	Context KeyValueQueryRequestContext `json:"context,omitempty" configurable:"true" title:"Context"`
-	Query   string                      `json:"query,omitempty" required:"true" title:"Query"`
+	Key     string                      `json:"key" required:"true" title:"Key" description:"Primary key value of the record"`
+}
+
+type HistoryResult struct {
+	Context KeyValueQueryRequestContext `json:"context"`
+	Key     string                      `json:"key"`
+	Entries []HistoryEntry              `json:"entries"`
+}
+
+type KeyValueQueryRequest struct {
+	Context           KeyValueQueryRequestContext `json:"context,omitempty" configurable:"true" title:"Context"`
+	Query             string                      `json:"query,omitempty" required:"true" title:"Query" description:"JSONPath expression or key prefix depending on query mode"`
+	QueryMode         string                      `json:"queryMode,omitempty" enum:"jsonpath,prefix" enumTitles:"JSONPath,Key prefix" default:"jsonpath" title:"Query mode" description:"Prefix mode returns all records which keys start with the query"`
+	NumericRange      *NumericRange               `json:"numericRange,omitempty" title:"Numeric range" description:"Only records with numeric field within the range are considered"`
+	Projection        []string                    `json:"projection,omitempty" title:"Projection" description:"Dot separated paths of fields to return, e.g. address.city. Empty returns all fields"`
+	ExcludeProjection []string                    `json:"excludeProjection,omitempty" title:"Exclude projection" description:"Dot separated paths of fields not to return"`
+}
+
+type NumericRange struct {
+	Field string  `json:"field" required:"true" title:"Field"`
+	Min   float64 `json:"min" title:"Min"`
+	Max   float64 `json:"max" title:"Max"`
 }
 
 type KeyValueQueryResult struct {
+	Context   KeyValueQueryRequestContext `json:"context"`
+	Document  KeyValueStoreDocument       `json:"document"`
+	Documents []KeyValueStoreDocument     `json:"documents,omitempty"`
+	Found     bool                        `json:"found"`
+	Query     string                      `json:"query"`
+}
+
+type AggregateRequest struct {
+	Context  KeyValueQueryRequestContext `json:"context,omitempty" configurable:"true" title:"Context"`
+	Field    string                      `json:"field" required:"true" title:"Field" description:"Numeric field to aggregate"`
+	Function string                      `json:"function" required:"true" enum:"sum,avg,min,max" enumTitles:"Sum,Average,Min,Max" default:"sum" title:"Function"`
+	Filter   string                      `json:"filter,omitempty" title:"Filter" description:"Optional JSONPath expression, only matching records are aggregated"`
+}
+
+type AggregateResult struct {
 	Context  KeyValueQueryRequestContext `json:"context"`
-	Document KeyValueStoreDocument       `json:"document"`
-	Found    bool                        `json:"found"`
-	Query    string                      `json:"query"`
+	Value    float64                     `json:"value"`
+	Count    int                         `json:"count"`
+	Field    string                      `json:"field"`
+	Function string                      `json:"function"`
 }
 
 type KeyValueStoreRequest struct {
 	Context   KeyValueStoreRequestContext `json:"context,omitempty" title:"Context" configurable:"true"`
-	Operation string                      `json:"operation" required:"true" enum:"store,delete" enumTitles:"Store,Delete" default:"store" title:"Operation"`
+	Operation string                      `json:"operation" required:"true" enum:"store,delete,insert,update,increment,decrement" enumTitles:"Store,Delete,Insert,Update,Increment,Decrement" default:"store" title:"Operation" description:"Store creates or replaces the record, insert fails if it already exists, update fails if it does not exist. Increment and decrement change numeric field of the record, creating it from the document if needed"`
 	Document  KeyValueStoreDocument       `json:"document" required:"true" title:"Document" description:"Document to be stored"`
+	Field     string                      `json:"field,omitempty" title:"Field" description:"Numeric field to increment or decrement" default:"value"`
+	IncrBy    float64                     `json:"incrBy,omitempty" title:"Increment by" description:"Value added or subtracted by increment and decrement. Zero means 1" default:"1"`
 }
 
 type KeyValueStoreResult struct {
-	Request KeyValueStoreRequest `json:"request"`
+	Request  KeyValueStoreRequest `json:"request"`
+	NewValue float64              `json:"newValue,omitempty" description:"Value of the field after increment or decrement"`
+}
+
+type ChangeEvent struct {
+	Operation        string                `json:"operation"`
+	Key              string                `json:"key"`
+	Document         KeyValueStoreDocument `json:"document" description:"Record after the change, empty if it was deleted"`
+	PreviousDocument KeyValueStoreDocument `json:"previousDocument" description:"Record before the change, empty if it did not exist"`
+	Timestamp        time.Time             `json:"timestamp"`
+}
+
+// change describes result of applied operation
+type change struct {
+	previous []byte
+	document KeyValueStoreDocument
+	value    float64
+	evicted  *EvictedRecord
+}
+
+type ConflictError struct {
+	Context   KeyValueStoreRequestContext `json:"context"`
+	Key       string                      `json:"key"`
+	Operation string                      `json:"operation"`
+}
+
+func (e ConflictError) Error() string {
+	if e.Operation == OpInsert {
+		return fmt.Sprintf("record %s already exists", e.Key)
+	}
+	return fmt.Sprintf("record %s does not exist", e.Key)
+}
+
+type ExportRequest struct {
+	Context KeyValueQueryRequestContext `json:"context,omitempty" configurable:"true" title:"Context"`
+}
+
+type ExportResult struct {
+	Context  KeyValueQueryRequestContext `json:"context"`
+	Snapshot string                      `json:"snapshot"`
+	Count    int                         `json:"count"`
+}
+
+type ImportRequest struct {
+	Context  KeyValueStoreRequestContext `json:"context,omitempty" configurable:"true" title:"Context"`
+	Snapshot string                      `json:"snapshot" required:"true" title:"Snapshot" description:"Snapshot created by the export port"`
+	Mode     string                      `json:"mode" required:"true" enum:"replace,merge" enumTitles:"Replace,Merge" default:"merge" title:"Mode" description:"Replace removes all existing records first, merge keeps them"`
+}
+
+type EvictedRecord struct {
+	Key      string                `json:"key"`
+	Document KeyValueStoreDocument `json:"document"`
 }
 
 func (k *KeyValueStore) GetInfo() module.ComponentInfo {
@@ -82,22 +243,34 @@ func (k *KeyValueStore) GetInfo() module.ComponentInfo {
 	}
 }
 
+// ValidateSettings checks settings without delivering them to the component
+func (s KeyValueStoreSettings) ValidateSettings() error {
+	if len(s.Document) == 0 {
+		return fmt.Errorf("please define atleast one key")
+	}
+	if s.PrimaryKey == "" {
+		return fmt.Errorf("primary key can not be empty")
+	}
+	if _, ok := s.Document[s.PrimaryKey]; !ok {
+		return fmt.Errorf("primary key is missing in the document")
+	}
+	if s.EvictionPolicy != "" && s.EvictionPolicy != EvictionError && s.EvictionPolicy != EvictionLRU {
+		return fmt.Errorf("unknown eviction policy: %s", s.EvictionPolicy)
+	}
+	return nil
+}
+
 func (k *KeyValueStore) Handle(ctx context.Context, output module.Handler, port string, msg interface{}) error {
 	if port == module.SettingsPort {
 		in, ok := msg.(KeyValueStoreSettings)
 		if !ok {
 			return fmt.Errorf("invalid settings")
 		}
-		if len(in.Document) == 0 {
-			return fmt.Errorf("please define atleast one key")
-		}
-		if in.PrimaryKey == "" {
-			return fmt.Errorf("primary key can not be empty")
-		}
-		if _, ok := in.Document[in.PrimaryKey]; !ok {
-			return fmt.Errorf("primary key is missing in the document")
+		if err := in.ValidateSettings(); err != nil {
+			return err
 		}
 		k.settings = in
+		k.reindex()
 		return nil
 	}
 
@@ -119,22 +292,134 @@ func (k *KeyValueStore) Handle(ctx context.Context, output module.Handler, port
 			return fmt.Errorf("unable to encode message to store: %v", err)
 		}
 
-		if in.Operation == OpStore {
-			k.records.Set(pkValStr, data)
-		} else if in.Operation == OptDelete {
-			k.records.Remove(pkValStr)
+		var ch change
+		if in.Operation == OpIncrement || in.Operation == OpDecrement {
+			field := in.Field
+			if field == "" {
+				field = "value"
+			}
+			delta := in.IncrBy
+			if delta == 0 {
+				delta = 1
+			}
+			if in.Operation == OpDecrement {
+				delta = -delta
+			}
+			ch, err = k.increment(pkValStr, data, field, delta)
 		} else {
-			return fmt.Errorf("unknown operation: %s", in.Operation)
+			ch, err = k.apply(in.Operation, pkValStr, data)
+			ch.document = in.Document
+		}
+		var conflict ConflictError
+		if errors.As(err, &conflict) {
+			conflict.Context = in.Context
+			if k.settings.EnableErrorPort {
+				return output(ctx, PortError, conflict)
+			}
+			return conflict
+		}
+		if err != nil {
+			return err
+		}
+		if ch.evicted != nil && k.settings.EnableEvictedPort {
+			if err = output(ctx, PortEvicted, *ch.evicted); err != nil {
+				return err
+			}
+		}
+
+		if k.settings.EnableHistory {
+			k.addHistory(pkValStr, in.Operation, ch.document)
+		}
+
+		// deleting missing record changes nothing
+		if k.settings.EnableChangeStream && (in.Operation != OptDelete || ch.previous != nil) {
+			event := ChangeEvent{
+				Operation: in.Operation,
+				Key:       pkValStr,
+				Timestamp: time.Now(),
+			}
+			if in.Operation != OptDelete {
+				event.Document = ch.document
+			}
+			if ch.previous != nil {
+				if err = json.Unmarshal(ch.previous, &event.PreviousDocument); err != nil {
+					return fmt.Errorf("unable to decode previous record: %v", err)
+				}
+			}
+			if err = output(ctx, PortChangeStream, event); err != nil {
+				return err
+			}
 		}
 
 		if k.settings.EnableStoreAckPort {
 			return output(ctx, PortStoreAck, KeyValueStoreResult{
-				Request: in,
+				Request:  in,
+				NewValue: ch.value,
 			})
 		}
 		return nil
 	}
 
+	if port == PortExport {
+		in, ok := msg.(ExportRequest)
+		if !ok {
+			return fmt.Errorf("invalid export request")
+		}
+		snapshot, count, err := k.export()
+		if err != nil {
+			return err
+		}
+		return output(ctx, PortExportResult, ExportResult{
+			Context:  in.Context,
+			Snapshot: snapshot,
+			Count:    count,
+		})
+	}
+
+	if port == PortImport {
+		in, ok := msg.(ImportRequest)
+		if !ok {
+			return fmt.Errorf("invalid import request")
+		}
+		if in.Mode != ImportReplace && in.Mode != ImportMerge {
+			return fmt.Errorf("unknown import mode: %s", in.Mode)
+		}
+		return k.load(in.Snapshot, in.Mode)
+	}
+
+	if port == PortHistory {
+		in, ok := msg.(HistoryRequest)
+		if !ok {
+			return fmt.Errorf("invalid history request")
+		}
+		entries, _ := k.history.Get(in.Key)
+		return output(ctx, PortHistoryResult, HistoryResult{
+			Context: in.Context,
+			Key:     in.Key,
+			Entries: entries,
+		})
+	}
+
+	if port == PortAggregate {
+		in, ok := msg.(AggregateRequest)
+		if !ok {
+			return fmt.Errorf("invalid aggregate request")
+		}
+		if in.Field == "" {
+			return fmt.Errorf("empty field")
+		}
+		switch in.Function {
+		case AggSum, AggAvg, AggMin, AggMax:
+		default:
+			return fmt.Errorf("unknown aggregate function: %s", in.Function)
+		}
+		result, err := k.aggregate(in)
+		if err != nil {
+			return err
+		}
+		return output(ctx, PortAggregateResult, result)
+	}
+
 	if port != PortQuery {
 		return fmt.Errorf("unknown port")
 	}
@@ -147,22 +432,52 @@ func (k *KeyValueStore) Handle(ctx context.Context, output module.Handler, port
 		return fmt.Errorf("empty query")
 	}
 
-	for _, key := range k.records.Keys() {
-		data, _ := k.records.Get(key)
-		node, err := ajson.Unmarshal(data)
+	if in.QueryMode == QueryModePrefix {
+		docs, err := k.scanPrefix(in.Query, in.NumericRange)
 		if err != nil {
-			return fmt.Errorf("unable to encode stored message")
+			return err
 		}
-		jsonPathResult, err := ajson.Eval(node, in.Query)
-		if err != nil {
-			return fmt.Errorf("unable to eval query: %v", err)
+		for i, doc := range docs {
+			docs[i] = project(doc, in.Projection, in.ExcludeProjection)
 		}
-		v, err := jsonPathResult.Unpack()
+		return output(ctx, PortQueryResult, KeyValueQueryResult{
+			Query:     in.Query,
+			Context:   in.Context,
+			Documents: docs,
+			Found:     len(docs) > 0,
+		})
+	}
+
+	if in.QueryMode != "" && in.QueryMode != QueryModeJSONPath {
+		return fmt.Errorf("unknown query mode: %s", in.QueryMode)
+	}
+
+	keys, indexed := k.candidates(in.Query)
+	if !indexed {
+		keys = k.records.Keys()
+	}
+
+	for _, key := range keys {
+		data, ok := k.records.Get(key)
+		if !ok {
+			continue
+		}
+		if in.NumericRange != nil {
+			inRange, err := in.NumericRange.match(data)
+			if err != nil {
+				return err
+			}
+			if !inRange {
+				continue
+			}
+		}
+		found, err := match(data, in.Query)
 		if err != nil {
-			return fmt.Errorf("unable to get result: %v", err)
+			return err
 		}
-		if v == true {
+		if found {
 			// found it
+			k.accessed.Set(key, time.Now())
 			result := KeyValueStoreDocument{}
 			if err = json.Unmarshal(data, &result); err != nil {
 				return fmt.Errorf("unable to decode result: %v", err)
@@ -170,7 +485,7 @@ func (k *KeyValueStore) Handle(ctx context.Context, output module.Handler, port
 			return output(ctx, PortQueryResult, KeyValueQueryResult{
 				Query:    in.Query,
 				Context:  in.Context,
-				Document: result,
+				Document: project(result, in.Projection, in.ExcludeProjection),
 				Found:    true,
 			})
 		}
@@ -183,6 +498,510 @@ func (k *KeyValueStore) Handle(ctx context.Context, output module.Handler, port
 	})
 }
 
+// project keeps only included fields of the document and removes excluded ones, document is changed in place
+func project(doc KeyValueStoreDocument, include []string, exclude []string) KeyValueStoreDocument {
+	if len(include) > 0 {
+		projected := KeyValueStoreDocument{}
+		for _, path := range include {
+			if v, ok := getPath(doc, path); ok {
+				setPath(projected, path, v)
+			}
+		}
+		doc = projected
+	}
+	for _, path := range exclude {
+		deletePath(doc, path)
+	}
+	return doc
+}
+
+func getPath(doc map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func setPath(doc map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := doc[part].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			doc[part] = next
+		}
+		doc = next
+	}
+	doc[parts[len(parts)-1]] = value
+}
+
+func deletePath(doc map[string]interface{}, path string) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := doc[part].(map[string]interface{})
+		if !ok {
+			return
+		}
+		doc = next
+	}
+	delete(doc, parts[len(parts)-1])
+}
+
+// apply changes records according to the operation, returns previous record and evicted record if any
+func (k *KeyValueStore) apply(operation string, key string, data []byte) (change, error) {
+	// snapshot import and export wait until changes are done
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	k.writeLock.Lock()
+	defer k.writeLock.Unlock()
+
+	var ch change
+
+	switch operation {
+	case OpStore, OpInsert, OpUpdate:
+		// existence is checked under write lock so concurrent inserts can not both succeed
+		if operation == OpInsert && k.records.Has(key) || operation == OpUpdate && !k.records.Has(key) {
+			return ch, ConflictError{
+				Key:       key,
+				Operation: operation,
+			}
+		}
+		if max := k.settings.MaxRecords; max > 0 && !k.records.Has(key) && k.records.Count() >= max {
+			if k.settings.EvictionPolicy != EvictionLRU {
+				return ch, fmt.Errorf("store full")
+			}
+			var err error
+			if ch.evicted, err = k.evict(); err != nil {
+				return ch, err
+			}
+		}
+		k.records.Upsert(key, data, func(exist bool, old []byte, data []byte) []byte {
+			if exist {
+				ch.previous = old
+				k.unindex(key, old)
+			}
+			k.index(key, data)
+			return data
+		})
+		k.accessed.Set(key, time.Now())
+	case OptDelete:
+		if old, ok := k.records.Pop(key); ok {
+			ch.previous = old
+			k.unindex(key, old)
+		}
+		k.accessed.Remove(key)
+	default:
+		return ch, fmt.Errorf("unknown operation: %s", operation)
+	}
+	return ch, nil
+}
+
+// increment atomically adds delta to numeric field of the record, record is created from data if it does not exist
+func (k *KeyValueStore) increment(key string, data []byte, field string, delta float64) (change, error) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	k.writeLock.Lock()
+	defer k.writeLock.Unlock()
+
+	var ch change
+	if max := k.settings.MaxRecords; max > 0 && !k.records.Has(key) && k.records.Count() >= max {
+		if k.settings.EvictionPolicy != EvictionLRU {
+			return ch, fmt.Errorf("store full")
+		}
+		var err error
+		if ch.evicted, err = k.evict(); err != nil {
+			return ch, err
+		}
+	}
+
+	initial := KeyValueStoreDocument{}
+	if err := json.Unmarshal(data, &initial); err != nil {
+		return ch, fmt.Errorf("unable to decode document: %v", err)
+	}
+	if _, err := getNumber(initial, field); err != nil {
+		return ch, err
+	}
+
+	var err error
+	// record is locked while being changed
+	k.records.Upsert(key, nil, func(exist bool, old []byte, _ []byte) []byte {
+		doc := maps.Clone(initial)
+		if exist {
+			doc = KeyValueStoreDocument{}
+			if err = json.Unmarshal(old, &doc); err != nil {
+				err = fmt.Errorf("unable to decode record: %v", err)
+				return old
+			}
+		}
+		value, e := getNumber(doc, field)
+		if e != nil {
+			err = e
+			return old
+		}
+		value += delta
+		doc[field] = value
+
+		updated, _ := json.Marshal(doc)
+		if exist {
+			ch.previous = old
+			k.unindex(key, old)
+		}
+		k.index(key, updated)
+		ch.document, ch.value = doc, value
+		return updated
+	})
+	if err != nil {
+		return ch, err
+	}
+	k.accessed.Set(key, time.Now())
+	return ch, nil
+}
+
+// getNumber returns numeric field of the document, missing field is zero
+func getNumber(doc KeyValueStoreDocument, field string) (float64, error) {
+	v, ok := doc[field]
+	if !ok || v == nil {
+		return 0, nil
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("field %s is not a number", field)
+	}
+	return n, nil
+}
+
+// evict removes least recently accessed record
+func (k *KeyValueStore) evict() (*EvictedRecord, error) {
+	var (
+		oldestKey string
+		oldest    time.Time
+	)
+	for key, at := range k.accessed.Items() {
+		if oldestKey == "" || at.Before(oldest) {
+			oldestKey, oldest = key, at
+		}
+	}
+	if oldestKey == "" {
+		return nil, fmt.Errorf("store full")
+	}
+
+	data, _ := k.records.Get(oldestKey)
+	k.unindex(oldestKey, data)
+	k.records.Remove(oldestKey)
+	k.accessed.Remove(oldestKey)
+
+	doc := KeyValueStoreDocument{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unable to decode evicted record: %v", err)
+	}
+	return &EvictedRecord{
+		Key:      oldestKey,
+		Document: doc,
+	}, nil
+}
+
+// export encodes all records as a single JSON object keyed by primary key
+func (k *KeyValueStore) export() (string, int, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	snapshot := make(map[string]json.RawMessage, k.records.Count())
+	for key, data := range k.records.Items() {
+		snapshot[key] = data
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to encode snapshot: %v", err)
+	}
+	return string(data), len(snapshot), nil
+}
+
+// load restores records from a snapshot created by export
+func (k *KeyValueStore) load(snapshot string, mode string) error {
+	records := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(snapshot), &records); err != nil {
+		return fmt.Errorf("unable to decode snapshot: %v", err)
+	}
+
+	for key, data := range records {
+		doc := KeyValueStoreDocument{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("snapshot record %s is not an object", key)
+		}
+		if pk, ok := doc[k.settings.PrimaryKey].(string); !ok || pk != key {
+			return fmt.Errorf("snapshot record %s has no matching primary key", key)
+		}
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if max := k.settings.MaxRecords; max > 0 {
+		count := len(records)
+		if mode == ImportMerge {
+			count += k.records.Count()
+			for key := range records {
+				if k.records.Has(key) {
+					count--
+				}
+			}
+		}
+		if count > max {
+			return fmt.Errorf("snapshot exceeds max records: %d > %d", count, max)
+		}
+	}
+
+	if mode == ImportReplace {
+		k.records.Clear()
+		k.accessed.Clear()
+		k.history.Clear()
+	}
+	now := time.Now()
+	for key, data := range records {
+		k.records.Set(key, data)
+		k.accessed.Set(key, now)
+	}
+	k.reindex()
+	return nil
+}
+
+// equalityRe matches simple equality predicates like $.status == 'UP'
+var equalityRe = regexp.MustCompile(`^\s*\$\.([\w-]+)\s*==\s*(?:'([^']*)'|"([^"]*)"|(-?[\d.]+))\s*$`)
+
+func indexKey(field string, value interface{}) string {
+	return fmt.Sprintf("%s=%v", field, value)
+}
+
+func (k *KeyValueStore) isIndexed(field string) bool {
+	for _, idx := range k.settings.Indexes {
+		if idx.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *KeyValueStore) index(key string, data []byte) {
+	if len(k.settings.Indexes) == 0 {
+		return
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return
+	}
+	for _, idx := range k.settings.Indexes {
+		v, ok := doc[idx.Field]
+		if !ok {
+			continue
+		}
+		keys := k.indexes.Upsert(indexKey(idx.Field, v), cmap.ConcurrentMap[string, bool]{}, func(exist bool, keys cmap.ConcurrentMap[string, bool], _ cmap.ConcurrentMap[string, bool]) cmap.ConcurrentMap[string, bool] {
+			if exist {
+				return keys
+			}
+			return cmap.New[bool]()
+		})
+		keys.Set(key, true)
+	}
+}
+
+func (k *KeyValueStore) unindex(key string, data []byte) {
+	if len(k.settings.Indexes) == 0 {
+		return
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return
+	}
+	for _, idx := range k.settings.Indexes {
+		v, ok := doc[idx.Field]
+		if !ok {
+			continue
+		}
+		if keys, ok := k.indexes.Get(indexKey(idx.Field, v)); ok {
+			keys.Remove(key)
+		}
+	}
+}
+
+// reindex builds indexes from scratch
+func (k *KeyValueStore) reindex() {
+	k.indexes.Clear()
+	for key, data := range k.records.Items() {
+		k.index(key, data)
+	}
+}
+
+// candidates looks for an indexed equality predicate in the query and returns keys of records which may match,
+// false means full scan is needed
+func (k *KeyValueStore) candidates(query string) ([]string, bool) {
+	if query == "" || strings.Contains(query, "||") {
+		return nil, false
+	}
+	for _, part := range strings.Split(query, "&&") {
+		m := equalityRe.FindStringSubmatch(part)
+		if m == nil || !k.isIndexed(m[1]) {
+			continue
+		}
+		var value interface{} = m[2] + m[3]
+		if m[4] != "" {
+			// numbers are indexed as decoded from JSON
+			n, err := strconv.ParseFloat(m[4], 64)
+			if err != nil {
+				return nil, false
+			}
+			value = n
+		}
+		keys, ok := k.indexes.Get(indexKey(m[1], value))
+		if !ok {
+			return []string{}, true
+		}
+		return keys.Keys(), true
+	}
+	return nil, false
+}
+
+// match evaluates JSONPath query against stored document
+func match(data []byte, query string) (bool, error) {
+	node, err := ajson.Unmarshal(data)
+	if err != nil {
+		return false, fmt.Errorf("unable to encode stored message")
+	}
+	jsonPathResult, err := ajson.Eval(node, query)
+	if err != nil {
+		return false, fmt.Errorf("unable to eval query: %v", err)
+	}
+	v, err := jsonPathResult.Unpack()
+	if err != nil {
+		return false, fmt.Errorf("unable to get result: %v", err)
+	}
+	return v == true, nil
+}
+
+func (k *KeyValueStore) aggregate(in AggregateRequest) (AggregateResult, error) {
+	result := AggregateResult{
+		Context:  in.Context,
+		Field:    in.Field,
+		Function: in.Function,
+	}
+
+	keys, indexed := k.candidates(in.Filter)
+	if !indexed {
+		keys = k.records.Keys()
+	}
+
+	for _, key := range keys {
+		data, ok := k.records.Get(key)
+		if !ok {
+			continue
+		}
+		if in.Filter != "" {
+			found, err := match(data, in.Filter)
+			if err != nil {
+				return result, err
+			}
+			if !found {
+				continue
+			}
+		}
+		doc := map[string]interface{}{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return result, fmt.Errorf("unable to decode stored message: %v", err)
+		}
+		v, ok := doc[in.Field].(float64)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case result.Count == 0:
+			result.Value = v
+		case in.Function == AggMin:
+			result.Value = min(result.Value, v)
+		case in.Function == AggMax:
+			result.Value = max(result.Value, v)
+		default:
+			result.Value += v
+		}
+		result.Count++
+	}
+
+	if in.Function == AggAvg && result.Count > 0 {
+		result.Value = result.Value / float64(result.Count)
+	}
+	return result, nil
+}
+
+// scanPrefix returns all documents which keys start with prefix, sorted by key
+func (k *KeyValueStore) scanPrefix(prefix string, numericRange *NumericRange) ([]KeyValueStoreDocument, error) {
+	var keys []string
+	for _, key := range k.records.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	docs := make([]KeyValueStoreDocument, 0, len(keys))
+	for _, key := range keys {
+		data, ok := k.records.Get(key)
+		if !ok {
+			continue
+		}
+		if numericRange != nil {
+			inRange, err := numericRange.match(data)
+			if err != nil {
+				return nil, err
+			}
+			if !inRange {
+				continue
+			}
+		}
+		doc := KeyValueStoreDocument{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("unable to decode result: %v", err)
+		}
+		k.accessed.Set(key, time.Now())
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// match checks if stored document's numeric field is within the range, documents without the field never match
+func (r *NumericRange) match(data []byte) (bool, error) {
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return false, fmt.Errorf("unable to decode stored message: %v", err)
+	}
+	v, ok := doc[r.Field].(float64)
+	if !ok {
+		return false, nil
+	}
+	return v >= r.Min && v <= r.Max, nil
+}
+
+// addHistory prepends a new entry to the record's history, newest first
+func (k *KeyValueStore) addHistory(key string, operation string, doc KeyValueStoreDocument) {
+	entry := HistoryEntry{
+		Operation: operation,
+		Document:  doc,
+		At:        time.Now(),
+	}
+	k.history.Upsert(key, nil, func(exist bool, entries []HistoryEntry, _ []HistoryEntry) []HistoryEntry {
+		entries = append([]HistoryEntry{entry}, entries...)
+		if max := k.settings.MaxHistoryPerKey; max > 0 && len(entries) > max {
+			entries = entries[:max]
+		}
+		return entries
+	})
+}
+
 func (k *KeyValueStore) Ports() []module.Port {
 	ports := []module.Port{
 		{
@@ -195,6 +1014,45 @@ func (k *KeyValueStore) Ports() []module.Port {
 			Position: module.Left,
 		},
 
+		{
+			Name:   PortAggregate,
+			Label:  "Aggregate",
+			Source: true,
+			Configuration: AggregateRequest{
+				Function: AggSum,
+			},
+			Position: module.Left,
+		},
+		{
+			Name:          PortAggregateResult,
+			Label:         "Aggregate result",
+			Source:        false,
+			Configuration: AggregateResult{},
+			Position:      module.Right,
+		},
+		{
+			Name:          PortExport,
+			Label:         "Export",
+			Source:        true,
+			Configuration: ExportRequest{},
+			Position:      module.Left,
+		},
+		{
+			Name:          PortExportResult,
+			Label:         "Export result",
+			Source:        false,
+			Configuration: ExportResult{},
+			Position:      module.Right,
+		},
+		{
+			Name:   PortImport,
+			Label:  "Import",
+			Source: true,
+			Configuration: ImportRequest{
+				Mode: ImportMerge,
+			},
+			Position: module.Left,
+		},
 		{
 			Name:   PortStore,
 			Label:  "Store",
@@ -232,13 +1090,60 @@ func (k *KeyValueStore) Ports() []module.Port {
 			Position:      module.Right,
 		})
 	}
+	if k.settings.EnableChangeStream {
+		ports = append(ports, module.Port{
+			Name:          PortChangeStream,
+			Label:         "Change stream",
+			Source:        false,
+			Configuration: ChangeEvent{},
+			Position:      module.Right,
+		})
+	}
+	if k.settings.EnableEvictedPort {
+		ports = append(ports, module.Port{
+			Name:          PortEvicted,
+			Label:         "Evicted",
+			Source:        false,
+			Configuration: EvictedRecord{},
+			Position:      module.Bottom,
+		})
+	}
+	if k.settings.EnableErrorPort {
+		ports = append(ports, module.Port{
+			Name:          PortError,
+			Label:         "Error",
+			Source:        false,
+			Configuration: ConflictError{},
+			Position:      module.Bottom,
+		})
+	}
+	if k.settings.EnableHistory {
+		ports = append(ports, module.Port{
+			Name:          PortHistory,
+			Label:         "History",
+			Source:        true,
+			Configuration: HistoryRequest{},
+			Position:      module.Left,
+		}, module.Port{
+			Name:          PortHistoryResult,
+			Label:         "History result",
+			Source:        false,
+			Configuration: HistoryResult{},
+			Position:      module.Right,
+		})
+	}
 	return ports
 }
 
 func (k *KeyValueStore) Instance() module.Component {
 	return &KeyValueStore{
-		settings: KeyValueStoreSettings{}, // default settings
-		records:  cmap.New[[]byte](),
+		settings:  KeyValueStoreSettings{}, // default settings
+		lock:      &sync.RWMutex{},
+		writeLock: &sync.Mutex{},
+		records:   cmap.New[[]byte](),
+		accessed:  cmap.New[time.Time](),
+		indexes:   cmap.New[cmap.ConcurrentMap[string, bool]](),
+		history:   cmap.New[[]HistoryEntry](),
 	}
 }
 
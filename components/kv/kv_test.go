@@ -0,0 +1,632 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"github.com/tiny-systems/common-module/testharness"
+	"github.com/tiny-systems/module/module"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newStore(t *testing.T, settings KeyValueStoreSettings) *KeyValueStore {
+	k := (&KeyValueStore{}).Instance().(*KeyValueStore)
+	if settings.Document == nil {
+		settings.Document = KeyValueStoreDocument{"id": "", "status": ""}
+	}
+	if settings.PrimaryKey == "" {
+		settings.PrimaryKey = "id"
+	}
+	if err := k.Handle(context.Background(), nil, module.SettingsPort, settings); err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+	return k
+}
+
+func noop(ctx context.Context, port string, data interface{}) error {
+	return nil
+}
+
+func TestKeyValueStore_History(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{EnableHistory: true})
+
+	for _, req := range []KeyValueStoreRequest{
+		{Operation: OpStore, Document: KeyValueStoreDocument{"id": "a", "status": "UP"}},
+		{Operation: OpStore, Document: KeyValueStoreDocument{"id": "a", "status": "DOWN"}},
+		{Operation: OptDelete, Document: KeyValueStoreDocument{"id": "a"}},
+	} {
+		if err := k.Handle(context.Background(), noop, PortStore, req); err != nil {
+			t.Fatalf("store error: %v", err)
+		}
+	}
+
+	var result HistoryResult
+	err := k.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		if port != PortHistoryResult {
+			t.Fatalf("unexpected port: %s", port)
+		}
+		result = data.(HistoryResult)
+		return nil
+	}, PortHistory, HistoryRequest{Key: "a"})
+	if err != nil {
+		t.Fatalf("history error: %v", err)
+	}
+
+	if len(result.Entries) != 3 {
+		t.Fatalf("expected 3 history entries, got %d", len(result.Entries))
+	}
+	if result.Entries[0].Operation != OptDelete || result.Entries[1].Document["status"] != "DOWN" || result.Entries[2].Document["status"] != "UP" {
+		t.Errorf("history is not in reverse chronological order: %+v", result.Entries)
+	}
+	for i := 1; i < len(result.Entries); i++ {
+		if result.Entries[i].At.After(result.Entries[i-1].At) {
+			t.Errorf("entry %d is newer than entry %d", i, i-1)
+		}
+	}
+}
+
+func TestKeyValueStore_PrefixScan(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{})
+
+	for _, id := range []string{"ep/b", "ep/a", "pod/a", "epx"} {
+		if err := k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+			Operation: OpStore,
+			Document:  KeyValueStoreDocument{"id": id},
+		}); err != nil {
+			t.Fatalf("store error: %v", err)
+		}
+	}
+
+	var result KeyValueQueryResult
+	err := k.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		result = data.(KeyValueQueryResult)
+		return nil
+	}, PortQuery, KeyValueQueryRequest{Query: "ep/", QueryMode: QueryModePrefix})
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+
+	if !result.Found || len(result.Documents) != 2 {
+		t.Fatalf("expected 2 documents, got %+v", result.Documents)
+	}
+	if result.Documents[0]["id"] != "ep/a" || result.Documents[1]["id"] != "ep/b" {
+		t.Errorf("unexpected documents: %+v", result.Documents)
+	}
+}
+
+func TestKeyValueStore_NumericRange(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{})
+
+	for id, latency := range map[string]float64{"a": 5, "b": 50, "c": 500} {
+		_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+			Operation: OpStore,
+			Document:  KeyValueStoreDocument{"id": id, "latency": latency},
+		})
+	}
+
+	var result KeyValueQueryResult
+	_ = k.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		result = data.(KeyValueQueryResult)
+		return nil
+	}, PortQuery, KeyValueQueryRequest{
+		Query:        "$.latency > 0",
+		NumericRange: &NumericRange{Field: "latency", Min: 10, Max: 100},
+	})
+
+	if !result.Found || result.Document["id"] != "b" {
+		t.Errorf("expected document b, got %+v", result.Document)
+	}
+}
+
+func TestKeyValueStore_Aggregate(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{})
+
+	latencies := []float64{10, 20, 30, 45, 95}
+	for i, latency := range latencies {
+		_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+			Operation: OpStore,
+			Document:  KeyValueStoreDocument{"id": string(rune('a' + i)), "latency": latency},
+		})
+	}
+
+	var result AggregateResult
+	err := k.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		result = data.(AggregateResult)
+		return nil
+	}, PortAggregate, AggregateRequest{Field: "latency", Function: AggAvg})
+	if err != nil {
+		t.Fatalf("aggregate error: %v", err)
+	}
+
+	if result.Count != 5 || result.Value != 40 {
+		t.Errorf("expected avg 40 over 5 records, got %v over %d", result.Value, result.Count)
+	}
+
+	_ = k.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		result = data.(AggregateResult)
+		return nil
+	}, PortAggregate, AggregateRequest{Field: "latency", Function: AggMax, Filter: "$.latency < 50"})
+
+	if result.Count != 4 || result.Value != 45 {
+		t.Errorf("expected filtered max 45 over 4 records, got %v over %d", result.Value, result.Count)
+	}
+}
+
+func TestKeyValueStore_ConditionalStore(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{EnableErrorPort: true})
+
+	var conflicts []ConflictError
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == PortError {
+			conflicts = append(conflicts, data.(ConflictError))
+		}
+		return nil
+	}
+
+	for _, req := range []KeyValueStoreRequest{
+		{Operation: OpInsert, Document: KeyValueStoreDocument{"id": "a", "status": "UP"}},
+		{Operation: OpInsert, Document: KeyValueStoreDocument{"id": "a", "status": "DOWN"}},
+		{Operation: OpUpdate, Document: KeyValueStoreDocument{"id": "b", "status": "UP"}},
+		{Operation: OpUpdate, Document: KeyValueStoreDocument{"id": "a", "status": "DOWN"}},
+		{Operation: OpStore, Document: KeyValueStoreDocument{"id": "c", "status": "UP"}},
+		{Operation: OpStore, Document: KeyValueStoreDocument{"id": "c", "status": "DOWN"}},
+	} {
+		if err := k.Handle(context.Background(), handler, PortStore, req); err != nil {
+			t.Fatalf("store error: %v", err)
+		}
+	}
+
+	if len(conflicts) != 2 {
+		t.Fatalf("expected 2 conflicts, got %+v", conflicts)
+	}
+	if conflicts[0].Key != "a" || conflicts[0].Operation != OpInsert {
+		t.Errorf("expected insert conflict on a, got %+v", conflicts[0])
+	}
+	if conflicts[1].Key != "b" || conflicts[1].Operation != OpUpdate {
+		t.Errorf("expected update conflict on b, got %+v", conflicts[1])
+	}
+	if k.records.Count() != 2 || k.records.Has("b") {
+		t.Errorf("unexpected records: %v", k.records.Keys())
+	}
+
+	// without error port conflicts are returned as errors
+	k.settings.EnableErrorPort = false
+	err := k.Handle(context.Background(), handler, PortStore, KeyValueStoreRequest{Operation: OpInsert, Document: KeyValueStoreDocument{"id": "a"}})
+	if _, ok := err.(ConflictError); !ok {
+		t.Errorf("expected conflict error, got %v", err)
+	}
+}
+
+func TestKeyValueStore_ConcurrentInsert(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{})
+
+	var (
+		wg        sync.WaitGroup
+		succeeded atomic.Int32
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+				Operation: OpInsert,
+				Document:  KeyValueStoreDocument{"id": "a", "status": fmt.Sprintf("%d", i)},
+			})
+			if err == nil {
+				succeeded.Add(1)
+			} else if _, ok := err.(ConflictError); !ok {
+				t.Errorf("expected conflict error, got %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := succeeded.Load(); n != 1 {
+		t.Errorf("expected exactly one insert to succeed, got %d", n)
+	}
+}
+
+func TestKeyValueStore_EvictLRU(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{MaxRecords: 3, EvictionPolicy: EvictionLRU, EnableEvictedPort: true})
+
+	var evicted []EvictedRecord
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == PortEvicted {
+			evicted = append(evicted, data.(EvictedRecord))
+		}
+		return nil
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		_ = k.Handle(context.Background(), handler, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: KeyValueStoreDocument{"id": id}})
+		time.Sleep(time.Millisecond)
+	}
+	// touch a so b becomes the least recently used
+	_ = k.Handle(context.Background(), handler, PortQuery, KeyValueQueryRequest{Query: "$.id == 'a'"})
+	time.Sleep(time.Millisecond)
+
+	if err := k.Handle(context.Background(), handler, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: KeyValueStoreDocument{"id": "d"}}); err != nil {
+		t.Fatalf("store error: %v", err)
+	}
+
+	if len(evicted) != 1 || evicted[0].Key != "b" {
+		t.Fatalf("expected b to be evicted, got %+v", evicted)
+	}
+	if k.records.Has("b") || k.records.Count() != 3 {
+		t.Errorf("unexpected records: %v", k.records.Keys())
+	}
+}
+
+func TestKeyValueStore_EvictConcurrent(t *testing.T) {
+	const max = 5
+	k := newStore(t, KeyValueStoreSettings{MaxRecords: max, EvictionPolicy: EvictionLRU, EnableEvictedPort: true})
+
+	var (
+		evictedLock sync.Mutex
+		evicted     = map[string]int{}
+	)
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == PortEvicted {
+			evictedLock.Lock()
+			evicted[data.(EvictedRecord).Key]++
+			evictedLock.Unlock()
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			op := OpStore
+			if i%2 == 0 {
+				op = OpIncrement
+			}
+			_ = k.Handle(context.Background(), handler, PortStore, KeyValueStoreRequest{
+				Operation: op,
+				Document:  KeyValueStoreDocument{"id": fmt.Sprintf("key-%d", i)},
+			})
+			if count := k.records.Count(); count > max {
+				t.Errorf("store grew past max records: %d", count)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if count := k.records.Count(); count != max {
+		t.Errorf("expected %d records, got %d", max, count)
+	}
+	for key, n := range evicted {
+		if n > 1 {
+			t.Errorf("record %s evicted %d times", key, n)
+		}
+	}
+	if len(evicted) != 200-max {
+		t.Errorf("expected %d evicted records, got %d", 200-max, len(evicted))
+	}
+}
+
+func TestKeyValueStore_Full(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{MaxRecords: 1})
+
+	_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: KeyValueStoreDocument{"id": "a"}})
+	err := k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: KeyValueStoreDocument{"id": "b"}})
+	if err == nil || err.Error() != "store full" {
+		t.Errorf("expected store full error, got %v", err)
+	}
+}
+
+func TestKeyValueStore_ExportImport(t *testing.T) {
+	src := newStore(t, KeyValueStoreSettings{})
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		_ = src.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: KeyValueStoreDocument{"id": id, "status": "UP"}})
+	}
+
+	var exported ExportResult
+	err := src.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		exported = data.(ExportResult)
+		return nil
+	}, PortExport, ExportRequest{})
+	if err != nil {
+		t.Fatalf("export error: %v", err)
+	}
+	if exported.Count != 5 {
+		t.Fatalf("expected 5 exported records, got %d", exported.Count)
+	}
+
+	dst := newStore(t, KeyValueStoreSettings{})
+	_ = dst.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: KeyValueStoreDocument{"id": "x"}})
+
+	if err = dst.Handle(context.Background(), noop, PortImport, ImportRequest{Snapshot: exported.Snapshot, Mode: ImportReplace}); err != nil {
+		t.Fatalf("import error: %v", err)
+	}
+	if dst.records.Count() != 5 || dst.records.Has("x") {
+		t.Fatalf("unexpected records after import: %v", dst.records.Keys())
+	}
+
+	var result KeyValueQueryResult
+	_ = dst.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		result = data.(KeyValueQueryResult)
+		return nil
+	}, PortQuery, KeyValueQueryRequest{Query: "$.id == 'c'"})
+	if !result.Found || result.Document["status"] != "UP" {
+		t.Errorf("imported record is not queryable: %+v", result)
+	}
+}
+
+func TestKeyValueStore_ImportInvalid(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{MaxRecords: 2})
+	_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: KeyValueStoreDocument{"id": "x"}})
+
+	for name, snapshot := range map[string]string{
+		"not an object":       `{"a":5}`,
+		"missing primary key": `{"a":{"status":"UP"}}`,
+		"primary key differs": `{"a":{"id":"b"}}`,
+	} {
+		if err := k.Handle(context.Background(), noop, PortImport, ImportRequest{Snapshot: snapshot, Mode: ImportMerge}); err == nil {
+			t.Errorf("%s: expected import error", name)
+		}
+	}
+
+	err := k.Handle(context.Background(), noop, PortImport, ImportRequest{Snapshot: `{"a":{"id":"a"},"b":{"id":"b"}}`, Mode: ImportMerge})
+	if err == nil {
+		t.Error("expected merge beyond max records to fail")
+	}
+	err = k.Handle(context.Background(), noop, PortImport, ImportRequest{Snapshot: `{"a":{"id":"a"},"b":{"id":"b"},"c":{"id":"c"}}`, Mode: ImportReplace})
+	if err == nil {
+		t.Error("expected replace beyond max records to fail")
+	}
+	if k.records.Count() != 1 || !k.records.Has("x") {
+		t.Errorf("failed import changed records: %v", k.records.Keys())
+	}
+}
+
+func TestKeyValueStore_ImportReplace(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{EnableHistory: true, MaxRecords: 2})
+	_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: KeyValueStoreDocument{"id": "a", "status": "DOWN"}})
+	_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: KeyValueStoreDocument{"id": "b"}})
+
+	err := k.Handle(context.Background(), noop, PortImport, ImportRequest{Snapshot: `{"a":{"id":"a","status":"UP"},"c":{"id":"c"}}`, Mode: ImportReplace})
+	if err != nil {
+		t.Fatalf("import error: %v", err)
+	}
+	if k.records.Count() != 2 || k.records.Has("b") {
+		t.Errorf("unexpected records after import: %v", k.records.Keys())
+	}
+	if k.history.Count() != 0 {
+		t.Errorf("expected history to be cleared, got %v", k.history.Keys())
+	}
+}
+
+func TestKeyValueStore_Index(t *testing.T) {
+	settings := KeyValueStoreSettings{Document: KeyValueStoreDocument{"id": "", "status": "", "n": 0}, PrimaryKey: "id"}
+	k := newStore(t, settings)
+
+	for i := 0; i < 1000; i++ {
+		status := "DOWN"
+		if i%4 == 0 {
+			status = "UP"
+		}
+		_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+			Operation: OpStore,
+			Document:  KeyValueStoreDocument{"id": fmt.Sprintf("ep/%d", i), "status": status, "n": i},
+		})
+	}
+
+	settings.Indexes = []IndexDef{{Field: "status"}}
+	if err := k.Handle(context.Background(), nil, module.SettingsPort, settings); err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+
+	query := "$.status == 'UP'"
+	keys, indexed := k.candidates(query)
+	if !indexed {
+		t.Fatal("query should use the index")
+	}
+	if len(keys) != 250 {
+		t.Errorf("index should return only matching records, got %d", len(keys))
+	}
+
+	var result AggregateResult
+	_ = k.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		result = data.(AggregateResult)
+		return nil
+	}, PortAggregate, AggregateRequest{Field: "n", Function: AggSum, Filter: query})
+	if result.Count != 250 {
+		t.Errorf("expected 250 matching records, got %d", result.Count)
+	}
+
+	// index follows updates
+	_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+		Operation: OpStore,
+		Document:  KeyValueStoreDocument{"id": "ep/0", "status": "DOWN", "n": 0},
+	})
+	_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+		Operation: OptDelete,
+		Document:  KeyValueStoreDocument{"id": "ep/4"},
+	})
+	if keys, _ = k.candidates(query + " && $.n > 10"); len(keys) != 248 {
+		t.Errorf("expected 248 indexed records after update, got %d", len(keys))
+	}
+}
+
+func TestKeyValueStore_IndexNumber(t *testing.T) {
+	settings := KeyValueStoreSettings{
+		Document:   KeyValueStoreDocument{"id": "", "n": 0},
+		PrimaryKey: "id",
+		Indexes:    []IndexDef{{Field: "n"}},
+	}
+	k := newStore(t, settings)
+
+	_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+		Operation: OpStore,
+		Document:  KeyValueStoreDocument{"id": "a", "n": 1},
+	})
+	_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+		Operation: OpStore,
+		Document:  KeyValueStoreDocument{"id": "b", "n": 1e21},
+	})
+
+	for query, expected := range map[string]int{
+		"$.n == 1.0":                    1,
+		"$.n == 1":                      1,
+		"$.n == 1000000000000000000000": 1,
+		"$.n == 2":                      0,
+	} {
+		keys, indexed := k.candidates(query)
+		if !indexed {
+			t.Errorf("query %s should use the index", query)
+		}
+		if len(keys) != expected {
+			t.Errorf("query %s: expected %d indexed records, got %d", query, expected, len(keys))
+		}
+
+		var result AggregateResult
+		_ = k.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+			result = data.(AggregateResult)
+			return nil
+		}, PortAggregate, AggregateRequest{Field: "n", Function: AggSum, Filter: query})
+		if result.Count != expected {
+			t.Errorf("query %s: expected %d matching records, got %d", query, expected, result.Count)
+		}
+	}
+}
+
+func TestKeyValueStore_Increment(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{EnableStoreAckPort: true})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+				Operation: OpIncrement,
+				Document:  KeyValueStoreDocument{"id": "counter"},
+			}); err != nil {
+				t.Errorf("increment error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var ack KeyValueStoreResult
+	err := k.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		ack = data.(KeyValueStoreResult)
+		return nil
+	}, PortStore, KeyValueStoreRequest{
+		Operation: OpDecrement,
+		Document:  KeyValueStoreDocument{"id": "counter"},
+		IncrBy:    0.5,
+	})
+	if err != nil {
+		t.Fatalf("decrement error: %v", err)
+	}
+	if ack.NewValue != 99.5 {
+		t.Errorf("expected 99.5 after 100 increments and decrement by 0.5, got %v", ack.NewValue)
+	}
+
+	err = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+		Operation: OpIncrement,
+		Document:  KeyValueStoreDocument{"id": "counter"},
+		Field:     "status",
+	})
+	if err != nil {
+		t.Fatalf("missing field should start from zero: %v", err)
+	}
+	_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+		Operation: OpStore,
+		Document:  KeyValueStoreDocument{"id": "text", "status": "UP"},
+	})
+	if err = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{
+		Operation: OpIncrement,
+		Document:  KeyValueStoreDocument{"id": "text"},
+		Field:     "status",
+	}); err == nil {
+		t.Error("expected error incrementing non numeric field")
+	}
+}
+
+func TestKeyValueStore_ChangeStream(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{EnableChangeStream: true})
+
+	var events []ChangeEvent
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == PortChangeStream {
+			events = append(events, data.(ChangeEvent))
+		}
+		return nil
+	}
+
+	for _, req := range []KeyValueStoreRequest{
+		{Operation: OpStore, Document: KeyValueStoreDocument{"id": "a", "status": "UP"}},
+		{Operation: OpStore, Document: KeyValueStoreDocument{"id": "a", "status": "DOWN"}},
+		{Operation: OptDelete, Document: KeyValueStoreDocument{"id": "missing"}},
+	} {
+		if err := k.Handle(context.Background(), handler, PortStore, req); err != nil {
+			t.Fatalf("store error: %v", err)
+		}
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 change events, got %d", len(events))
+	}
+	if events[0].PreviousDocument != nil || events[0].Document["status"] != "UP" {
+		t.Errorf("unexpected insert event: %+v", events[0])
+	}
+	if events[1].PreviousDocument["status"] != "UP" || events[1].Document["status"] != "DOWN" || events[1].Key != "a" {
+		t.Errorf("unexpected update event: %+v", events[1])
+	}
+}
+
+func TestKeyValueStore_Projection(t *testing.T) {
+	k := newStore(t, KeyValueStoreSettings{})
+
+	doc := KeyValueStoreDocument{"id": "a", "address": map[string]interface{}{"city": "Paris", "zip": "75001"}}
+	for i := 0; i < 8; i++ {
+		doc[fmt.Sprintf("field%d", i)] = i
+	}
+	_ = k.Handle(context.Background(), noop, PortStore, KeyValueStoreRequest{Operation: OpStore, Document: doc})
+
+	query := func(req KeyValueQueryRequest) KeyValueStoreDocument {
+		var result KeyValueQueryResult
+		req.Query = "$.id == 'a'"
+		if err := k.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+			result = data.(KeyValueQueryResult)
+			return nil
+		}, PortQuery, req); err != nil {
+			t.Fatalf("query error: %v", err)
+		}
+		return result.Document
+	}
+
+	got := query(KeyValueQueryRequest{Projection: []string{"id", "address.city"}})
+	want := KeyValueStoreDocument{"id": "a", "address": map[string]interface{}{"city": "Paris"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got = query(KeyValueQueryRequest{ExcludeProjection: []string{"address.zip", "field0"}})
+	if len(got) != 9 || got["address"].(map[string]interface{})["zip"] != nil || got["field0"] != nil {
+		t.Errorf("excluded fields are still present: %v", got)
+	}
+}
+
+func TestKeyValueStore_ValidateSettings(t *testing.T) {
+	testharness.AssertValidSettings(t, (&KeyValueStore{}).Instance())
+
+	if err := (KeyValueStoreSettings{}).ValidateSettings(); err == nil {
+		t.Error("expected zero value settings to be invalid")
+	}
+	if err := (KeyValueStoreSettings{
+		PrimaryKey:     "id",
+		Document:       KeyValueStoreDocument{"id": "ID"},
+		EvictionPolicy: "random",
+	}).ValidateSettings(); err == nil {
+		t.Error("expected unknown eviction policy to be invalid")
+	}
+}
@@ -9,18 +9,40 @@ import (
 	"github.com/tiny-systems/module/pkg/schema"
 	"github.com/tiny-systems/module/registry"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
 	ComponentName        = "mixer"
 	OutputPort    string = "output"
+	ErrorPort     string = "error"
+
+	HistoryPort       string = "history"
+	HistoryResultPort string = "history_result"
+
+	maxInputHistorySize = 100
 )
 
+const defaultPriorityWindow = 10 * time.Millisecond
+
 type Mixer struct {
 	settings Settings
 	//
 	inputs cmap.ConcurrentMap[string, interface{}]
 	output Output
+	// recent values per input name, oldest first
+	history cmap.ConcurrentMap[string, []interface{}]
+
+	// round collects triggers arrived within priority window
+	round     *priorityRound
+	roundLock *sync.Mutex
+}
+
+type priorityRound struct {
+	winner string
+	weight float64
+	done   chan struct{}
 }
 
 type Context any
@@ -71,7 +93,7 @@ func (m Input) Process(s *jsonschema.Schema) {
 }
 
 type Output struct {
-	inputNames []string
+	inputs []InputSettings
 }
 
 func (m Output) Process(s *jsonschema.Schema) {
@@ -86,12 +108,12 @@ func (m Output) Process(s *jsonschema.Schema) {
 
 	output.WithType(jsonschema.Object.Type())
 	//
-	for _, input := range m.inputNames {
-		defName := getDefinitionName(input)
-		propName := getPropName(input)
+	for _, input := range m.inputs {
+		defName := getDefinitionName(input.Name)
+		propName := input.fieldName()
 
 		def := jsonschema.Schema{}
-		def.WithDescription(fmt.Sprintf("Arbitrary message %s", input))
+		def.WithDescription(fmt.Sprintf("Arbitrary message %s", input.Name))
 		defs[defName] = def
 
 		ref := jsonschema.Schema{}
@@ -104,17 +126,106 @@ func (m Output) Process(s *jsonschema.Schema) {
 		WithTitle("From").
 		WithDescription("Name of the port initiated the signal").ToSchemaOrBool())
 
+	output.WithPropertiesItem("inputCount", (&jsonschema.Schema{}).
+		WithType(jsonschema.Integer.Type()).
+		WithTitle("Input count").
+		WithDescription("Number of inputs received a message").ToSchemaOrBool())
+
+	output.WithPropertiesItem("totalInputs", (&jsonschema.Schema{}).
+		WithType(jsonschema.Integer.Type()).
+		WithTitle("Total inputs").
+		WithDescription("Number of configured inputs").ToSchemaOrBool())
+
+	output.WithPropertiesItem("presentInputs", (&jsonschema.Schema{}).
+		WithType(jsonschema.Array.Type()).
+		WithItems(*(&jsonschema.Items{}).WithSchemaOrBool((&jsonschema.Schema{}).WithType(jsonschema.String.Type()).ToSchemaOrBool())).
+		WithTitle("Present inputs").
+		WithDescription("Names of inputs received a message").ToSchemaOrBool())
+
 	defs["Output"] = output
 	return
 }
 
 type InputSettings struct {
-	Name    string `json:"name" required:"true" title:"Input Name"`
-	Trigger bool   `json:"trigger" required:"true" title:"Trigger mode" description:"If enabled this input will trigger sending mixed output message"`
+	Name    string  `json:"name" required:"true" title:"Input Name"`
+	Trigger bool    `json:"trigger" required:"true" title:"Trigger mode" description:"If enabled this input will trigger sending mixed output message"`
+	Weight  float64 `json:"weight" title:"Weight" description:"In priority mode only trigger with the highest weight among simultaneous ones sends output message. Unset weight is 1" default:"1"`
+
+	OutputFieldName string `json:"outputFieldName,omitempty" title:"Output field name" description:"Name of the output message field carrying input value. Default is context followed by input name"`
+}
+
+// weight returns priority weight of the input, unset weight is 1
+func (i InputSettings) weight() float64 {
+	if i.Weight == 0 {
+		return 1
+	}
+	return i.Weight
+}
+
+// fieldName returns name of the output message field for the input
+func (i InputSettings) fieldName() string {
+	if i.OutputFieldName != "" {
+		return i.OutputFieldName
+	}
+	return getPropName(i.Name)
 }
 
 type Settings struct {
-	Inputs []InputSettings `json:"inputs" required:"true" title:"Inputs" minItems:"1" uniqueItems:"true"`
+	Inputs           []InputSettings `json:"inputs" required:"true" title:"Inputs" minItems:"1" uniqueItems:"true"`
+	EnableErrorPort  bool            `json:"enableErrorPort" title:"Enable error port" description:"If output message was not handled successfully, error is sent to error port instead of being returned"`
+	PriorityMode     bool            `json:"priorityMode" title:"Priority mode" description:"Triggers arrived within priority window compete by weight, others are stored without sending output"`
+	PriorityWindowMs int             `json:"priorityWindowMs" title:"Priority window (ms)" description:"Triggers arrived within this time are treated as simultaneous. Zero means 10ms" minimum:"0" default:"10"`
+	InputHistorySize int             `json:"inputHistorySize" title:"Input history size" description:"Number of recent values kept for each input and available through history port. Zero disables history" minimum:"0" maximum:"100" default:"0"`
+}
+
+// ValidateSettings checks settings without delivering them to the component
+func (s Settings) ValidateSettings() error {
+	if len(s.Inputs) == 0 {
+		return fmt.Errorf("please define at least one input")
+	}
+	if s.PriorityWindowMs < 0 {
+		return fmt.Errorf("priority window can not be negative")
+	}
+	if s.InputHistorySize < 0 || s.InputHistorySize > maxInputHistorySize {
+		return fmt.Errorf("input history size should be between 0 and %d", maxInputHistorySize)
+	}
+	if s.EnableErrorPort && findInput(s, ErrorPort) != nil {
+		return fmt.Errorf("input name %s is reserved for error port", ErrorPort)
+	}
+	if s.InputHistorySize > 0 && findInput(s, HistoryPort) != nil {
+		return fmt.Errorf("input name %s is reserved for history port", HistoryPort)
+	}
+	names := make(map[string]bool, len(s.Inputs))
+	fields := map[string]bool{"from": true, "inputCount": true, "totalInputs": true, "presentInputs": true}
+	for _, i := range s.Inputs {
+		if i.Name == "" {
+			return fmt.Errorf("input name can not be empty")
+		}
+		if names[i.Name] {
+			return fmt.Errorf("duplicate input name: %s", i.Name)
+		}
+		names[i.Name] = true
+		if fields[i.fieldName()] {
+			return fmt.Errorf("output field name %s is already in use", i.fieldName())
+		}
+		fields[i.fieldName()] = true
+	}
+	return nil
+}
+
+type HistoryRequest struct {
+	Context Context `json:"context" configurable:"true" title:"Context" description:"Arbitrary message to be send further"`
+}
+
+type HistoryResult struct {
+	Context Context                  `json:"context"`
+	Inputs  map[string][]interface{} `json:"inputs" description:"Recent values of each input, oldest first"`
+}
+
+type MixerError struct {
+	Error       string         `json:"error"`
+	Inputs      map[string]any `json:"inputs"`
+	TriggerPort string         `json:"triggerPort"`
 }
 
 func (m *Mixer) GetInfo() module.ComponentInfo {
@@ -133,19 +244,30 @@ func (m *Mixer) Handle(ctx context.Context, output module.Handler, port string,
 		if !ok {
 			return fmt.Errorf("invalid settings")
 		}
+		if err := in.ValidateSettings(); err != nil {
+			return err
+		}
 		m.settings = in
 		// reset state after new settings
 		m.inputs.Clear()
+		m.history.Clear()
 
-		var inputNames = make([]string, len(in.Inputs))
-		for k, v := range in.Inputs {
-			inputNames[k] = v.Name
-		}
-		m.output.inputNames = inputNames
+		m.output.inputs = in.Inputs
 
 		return nil
 	}
 
+	if port == HistoryPort && m.settings.InputHistorySize > 0 {
+		in, ok := msg.(HistoryRequest)
+		if !ok {
+			return fmt.Errorf("invalid history request")
+		}
+		return output(ctx, HistoryResultPort, HistoryResult{
+			Context: in.Context,
+			Inputs:  m.history.Items(),
+		})
+	}
+
 	is := m.hasInput(port)
 
 	if is == nil {
@@ -157,19 +279,92 @@ func (m *Mixer) Handle(ctx context.Context, output module.Handler, port string,
 		return fmt.Errorf("invalid message type: %T", msg)
 	}
 
-	m.inputs.Set(getPropName(port), in.Context)
+	m.inputs.Set(is.fieldName(), in.Context)
+	if size := m.settings.InputHistorySize; size > 0 {
+		m.history.Upsert(port, nil, func(exist bool, values []interface{}, _ []interface{}) []interface{} {
+			values = append(values, in.Context)
+			if len(values) > size {
+				values = values[len(values)-size:]
+			}
+			return values
+		})
+	}
 	if !is.Trigger {
 		return nil
 	}
+	if m.settings.PriorityMode && !m.wins(port, is.weight()) {
+		// stored, but another trigger takes precedence
+		return nil
+	}
 	// sending message
-	data := m.inputs.Items()
+	inputs := m.inputs.Items()
+
+	present := make([]string, 0, len(m.settings.Inputs))
+	for _, i := range m.settings.Inputs {
+		if v, ok := inputs[i.fieldName()]; ok && v != nil {
+			present = append(present, i.Name)
+		}
+	}
+
+	data := make(map[string]interface{}, len(inputs)+4)
+	for k, v := range inputs {
+		data[k] = v
+	}
 	data["from"] = port
+	data["inputCount"] = len(present)
+	data["totalInputs"] = len(m.settings.Inputs)
+	data["presentInputs"] = present
+
+	err := output(ctx, OutputPort, data)
+	if err == nil || !m.settings.EnableErrorPort {
+		return err
+	}
+
+	return output(ctx, ErrorPort, MixerError{
+		Error:       err.Error(),
+		Inputs:      inputs,
+		TriggerPort: port,
+	})
+}
+
+// wins joins current priority round or starts a new one, blocks until the round is over.
+// Returns true if the port has the highest weight in the round, earliest arrival wins a tie.
+func (m *Mixer) wins(port string, weight float64) bool {
+	m.roundLock.Lock()
+	round := m.round
+	if round == nil {
+		round = &priorityRound{
+			winner: port,
+			weight: weight,
+			done:   make(chan struct{}),
+		}
+		m.round = round
 
-	return output(ctx, OutputPort, data)
+		window := time.Duration(m.settings.PriorityWindowMs) * time.Millisecond
+		if window == 0 {
+			window = defaultPriorityWindow
+		}
+		time.AfterFunc(window, func() {
+			m.roundLock.Lock()
+			m.round = nil
+			m.roundLock.Unlock()
+			close(round.done)
+		})
+	} else if weight > round.weight {
+		round.winner, round.weight = port, weight
+	}
+	m.roundLock.Unlock()
+
+	<-round.done
+	return round.winner == port
 }
 
 func (m *Mixer) hasInput(name string) *InputSettings {
-	for _, i := range m.settings.Inputs {
+	return findInput(m.settings, name)
+}
+
+func findInput(settings Settings, name string) *InputSettings {
+	for _, i := range settings.Inputs {
 		if i.Name == name {
 			return &i
 		}
@@ -193,6 +388,28 @@ func (m *Mixer) Ports() []module.Port {
 			Position:      module.Right,
 		},
 	}
+	if m.settings.InputHistorySize > 0 {
+		ports = append(ports, module.Port{
+			Name:          HistoryPort,
+			Label:         "History",
+			Source:        true,
+			Configuration: HistoryRequest{},
+			Position:      module.Left,
+		}, module.Port{
+			Name:          HistoryResultPort,
+			Label:         "History result",
+			Configuration: HistoryResult{},
+			Position:      module.Right,
+		})
+	}
+	if m.settings.EnableErrorPort {
+		ports = append(ports, module.Port{
+			Name:          ErrorPort,
+			Label:         "Error",
+			Configuration: MixerError{},
+			Position:      module.Bottom,
+		})
+	}
 	//
 	for _, input := range m.settings.Inputs {
 		ports = append(ports, module.Port{
@@ -211,8 +428,10 @@ func (m *Mixer) Ports() []module.Port {
 
 func (m *Mixer) Instance() module.Component {
 	return &Mixer{
-		settings: Settings{Inputs: []InputSettings{{Name: "A", Trigger: true}, {Name: "B", Trigger: true}}},
-		inputs:   cmap.New[interface{}](),
+		settings:  Settings{Inputs: []InputSettings{{Name: "A", Trigger: true}, {Name: "B", Trigger: true}}},
+		inputs:    cmap.New[interface{}](),
+		history:   cmap.New[[]interface{}](),
+		roundLock: &sync.Mutex{},
 	}
 }
 
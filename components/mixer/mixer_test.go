@@ -0,0 +1,228 @@
+package mixer
+
+import (
+	"context"
+	"fmt"
+	"github.com/tiny-systems/common-module/testharness"
+	"github.com/tiny-systems/module/module"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMixer_ErrorPort(t *testing.T) {
+	m := (&Mixer{}).Instance()
+	err := m.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Inputs:          []InputSettings{{Name: "A", Trigger: true}, {Name: "B"}},
+		EnableErrorPort: true,
+	})
+	if err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+
+	var (
+		outputs int
+		errs    []MixerError
+	)
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		switch port {
+		case OutputPort:
+			outputs++
+			return fmt.Errorf("downstream is broken")
+		case ErrorPort:
+			errs = append(errs, data.(MixerError))
+		}
+		return nil
+	}
+
+	if err = m.Handle(context.Background(), handler, "B", Input{Context: "b"}); err != nil {
+		t.Fatalf("input error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err = m.Handle(context.Background(), handler, "A", Input{Context: i}); err != nil {
+			t.Fatalf("error should be sent to error port, got %v", err)
+		}
+	}
+
+	if outputs != 2 || len(errs) != 2 {
+		t.Fatalf("expected mixer to keep processing triggers, got %d outputs and %d errors", outputs, len(errs))
+	}
+	e := errs[1]
+	if e.Error != "downstream is broken" || e.TriggerPort != "A" || e.Inputs[getPropName("A")] != 1 || e.Inputs[getPropName("B")] != "b" {
+		t.Errorf("unexpected mixer error: %+v", e)
+	}
+}
+
+func TestMixer_InputCount(t *testing.T) {
+	m := (&Mixer{}).Instance()
+	_ = m.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Inputs: []InputSettings{{Name: "A"}, {Name: "B"}, {Name: "C", Trigger: true}},
+	})
+
+	var data map[string]interface{}
+	handler := func(ctx context.Context, port string, msg interface{}) error {
+		data = msg.(map[string]interface{})
+		return nil
+	}
+
+	_ = m.Handle(context.Background(), handler, "A", Input{Context: "a"})
+	_ = m.Handle(context.Background(), handler, "C", Input{Context: "c"})
+
+	if data["inputCount"] != 2 || data["totalInputs"] != 3 {
+		t.Errorf("unexpected counts: %v", data)
+	}
+	if present, _ := data["presentInputs"].([]string); len(present) != 2 || present[0] != "A" || present[1] != "C" {
+		t.Errorf("unexpected present inputs: %v", data["presentInputs"])
+	}
+}
+
+func TestMixer_PriorityMode(t *testing.T) {
+	m := (&Mixer{}).Instance()
+	_ = m.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Inputs:       []InputSettings{{Name: "A", Trigger: true, Weight: 0.3}, {Name: "B", Trigger: true, Weight: 0.9}},
+		PriorityMode: true,
+	})
+
+	var (
+		lock sync.Mutex
+		from []interface{}
+	)
+	handler := func(ctx context.Context, port string, msg interface{}) error {
+		lock.Lock()
+		defer lock.Unlock()
+		from = append(from, msg.(map[string]interface{})["from"])
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for _, port := range []string{"A", "B"} {
+		wg.Add(1)
+		go func(port string) {
+			defer wg.Done()
+			_ = m.Handle(context.Background(), handler, port, Input{Context: port})
+		}(port)
+	}
+	wg.Wait()
+
+	if len(from) != 1 || from[0] != "B" {
+		t.Errorf("expected only B to trigger output, got %v", from)
+	}
+}
+
+func TestMixer_PriorityModeDefaultWeight(t *testing.T) {
+	m := (&Mixer{}).Instance()
+	_ = m.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Inputs:           []InputSettings{{Name: "A", Trigger: true, Weight: 0.3}, {Name: "B", Trigger: true}},
+		PriorityMode:     true,
+		PriorityWindowMs: 200,
+	})
+
+	var (
+		lock sync.Mutex
+		from []interface{}
+	)
+	handler := func(ctx context.Context, port string, msg interface{}) error {
+		lock.Lock()
+		defer lock.Unlock()
+		from = append(from, msg.(map[string]interface{})["from"])
+		return nil
+	}
+
+	// A arrives first, so it would win a tie
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = m.Handle(context.Background(), handler, "A", Input{Context: "A"})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	_ = m.Handle(context.Background(), handler, "B", Input{Context: "B"})
+	<-done
+
+	if len(from) != 1 || from[0] != "B" {
+		t.Errorf("expected B with default weight 1 to trigger output, got %v", from)
+	}
+}
+
+func TestMixer_History(t *testing.T) {
+	m := (&Mixer{}).Instance()
+	_ = m.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Inputs:           []InputSettings{{Name: "A"}, {Name: "B"}},
+		InputHistorySize: 3,
+	})
+
+	var result HistoryResult
+	handler := func(ctx context.Context, port string, msg interface{}) error {
+		if port == HistoryResultPort {
+			result = msg.(HistoryResult)
+		}
+		return nil
+	}
+
+	for i := 1; i <= 5; i++ {
+		_ = m.Handle(context.Background(), handler, "A", Input{Context: i})
+		_ = m.Handle(context.Background(), handler, "B", Input{Context: -i})
+	}
+	if err := m.Handle(context.Background(), handler, HistoryPort, HistoryRequest{Context: "ctx"}); err != nil {
+		t.Fatalf("history error: %v", err)
+	}
+
+	if result.Context != "ctx" || len(result.Inputs) != 2 {
+		t.Fatalf("unexpected history result: %+v", result)
+	}
+	for name, values := range result.Inputs {
+		if len(values) != 3 {
+			t.Errorf("expected 3 values for input %s, got %v", name, values)
+		}
+	}
+	if a := result.Inputs["A"]; a[0] != 3 || a[2] != 5 {
+		t.Errorf("expected most recent values oldest first, got %v", a)
+	}
+}
+
+func TestMixer_OutputFieldName(t *testing.T) {
+	m := (&Mixer{}).Instance()
+	err := m.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Inputs: []InputSettings{{Name: "A", OutputFieldName: "alpha"}, {Name: "B", OutputFieldName: "beta", Trigger: true}},
+	})
+	if err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+
+	var data map[string]interface{}
+	handler := func(ctx context.Context, port string, msg interface{}) error {
+		data = msg.(map[string]interface{})
+		return nil
+	}
+	_ = m.Handle(context.Background(), handler, "A", Input{Context: "a"})
+	_ = m.Handle(context.Background(), handler, "B", Input{Context: "b"})
+
+	if data["alpha"] != "a" || data["beta"] != "b" {
+		t.Errorf("unexpected output: %v", data)
+	}
+	if _, ok := data[getPropName("A")]; ok {
+		t.Errorf("default field name should not be used: %v", data)
+	}
+
+	err = m.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Inputs: []InputSettings{{Name: "A", OutputFieldName: "from"}},
+	})
+	if err == nil {
+		t.Error("expected error for reserved output field name")
+	}
+}
+
+func TestMixer_ValidateSettings(t *testing.T) {
+	testharness.AssertValidSettings(t, (&Mixer{}).Instance())
+
+	for name, settings := range map[string]Settings{
+		"zero value":      {},
+		"empty name":      {Inputs: []InputSettings{{Name: ""}}},
+		"duplicate name":  {Inputs: []InputSettings{{Name: "A"}, {Name: "A"}}},
+		"history size":    {Inputs: []InputSettings{{Name: "A"}}, InputHistorySize: maxInputHistorySize + 1},
+		"priority window": {Inputs: []InputSettings{{Name: "A"}}, PriorityWindowMs: -1},
+	} {
+		if err := settings.ValidateSettings(); err == nil {
+			t.Errorf("%s: expected settings to be invalid", name)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package priorityqueue
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"github.com/tiny-systems/module/module"
+	"github.com/tiny-systems/module/registry"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+)
+
+const (
+	ComponentName        = "priority_queue"
+	InPort        string = "in"
+	OutPort       string = "out"
+	StartPort     string = "start"
+	StopPort      string = "stop"
+	FullPort      string = "full"
+)
+
+type Context any
+
+type Settings struct {
+	MaxSize int `json:"maxSize" required:"true" title:"Max size" description:"Maximum number of queued items. When exceeded, item with the lowest priority is dropped. Zero means unlimited." minimum:"0" default:"1000"`
+}
+
+type PQItem struct {
+	Context  Context `json:"context" configurable:"true" title:"Context" description:"Arbitrary message to be queued"`
+	Priority int     `json:"priority" required:"true" title:"Priority" description:"Lower number means higher precedence"`
+	ID       string  `json:"id" title:"ID"`
+}
+
+type QueueFull struct {
+	Dropped PQItem `json:"dropped"`
+}
+
+type StartControl struct {
+	Start  bool   `json:"start" format:"button" title:"Start" required:"true" description:"Start draining the queue"`
+	Status string `json:"status" title:"Status" readonly:"true"`
+	Size   int    `json:"size" title:"Queue size" readonly:"true"`
+}
+
+type StopControl struct {
+	Stop   bool   `json:"stop" format:"button" title:"Stop" required:"true" description:"Stop draining the queue"`
+	Status string `json:"status" title:"Status" readonly:"true"`
+	Size   int    `json:"size" title:"Queue size" readonly:"true"`
+}
+
+type Start struct {
+}
+
+type Stop struct {
+}
+
+type entry struct {
+	item PQItem
+	seq  uint64
+}
+
+// items implements heap.Interface, equal priorities keep arrival order
+type items []*entry
+
+func (q items) Len() int { return len(q) }
+
+func (q items) Less(i, j int) bool {
+	if q[i].item.Priority == q[j].item.Priority {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].item.Priority < q[j].item.Priority
+}
+
+func (q items) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *items) Push(x any) { *q = append(*q, x.(*entry)) }
+
+func (q *items) Pop() any {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return e
+}
+
+type Component struct {
+	settings Settings
+
+	queue     items
+	seq       uint64
+	queueLock *sync.Mutex
+	// notify wakes up the drain loop when a new item arrives
+	notify chan struct{}
+
+	cancelFunc     context.CancelFunc
+	cancelFuncLock *sync.Mutex
+
+	runLock *sync.Mutex
+}
+
+func (t *Component) Instance() module.Component {
+	return &Component{
+		queueLock:      &sync.Mutex{},
+		notify:         make(chan struct{}, 1),
+		cancelFuncLock: &sync.Mutex{},
+		runLock:        &sync.Mutex{},
+		settings: Settings{
+			MaxSize: 1000,
+		},
+	}
+}
+
+func (t *Component) GetInfo() module.ComponentInfo {
+	return module.ComponentInfo{
+		Name:        ComponentName,
+		Description: "Priority Queue",
+		Info:        "Queues incoming messages and, while running, sends them further one by one starting from the highest priority. Next message being sent as soon as port unblocked.",
+		Tags:        []string{"SDK"},
+	}
+}
+
+func (t *Component) Handle(ctx context.Context, handler module.Handler, port string, msg interface{}) error {
+
+	switch port {
+	case module.SettingsPort:
+		in, ok := msg.(Settings)
+		if !ok {
+			return fmt.Errorf("invalid settings")
+		}
+		if in.MaxSize < 0 {
+			return fmt.Errorf("max size can not be negative")
+		}
+		t.settings = in
+		return nil
+
+	case module.ControlPort:
+		if msg == nil {
+			break
+		}
+		switch msg.(type) {
+		case StartControl:
+			return t.run(ctx, handler)
+		case StopControl:
+			return t.stop()
+		}
+
+	case StartPort:
+		return t.run(ctx, handler)
+
+	case StopPort:
+		return t.stop()
+
+	case InPort:
+		in, ok := msg.(PQItem)
+		if !ok {
+			return fmt.Errorf("invalid input message")
+		}
+		if dropped := t.push(in); dropped != nil {
+			return handler(ctx, FullPort, QueueFull{
+				Dropped: *dropped,
+			})
+		}
+		return nil
+	}
+
+	return fmt.Errorf("invalid port: %s", port)
+}
+
+// push adds item to the queue, returns dropped item if queue overflowed
+func (t *Component) push(item PQItem) *PQItem {
+	t.queueLock.Lock()
+	defer t.queueLock.Unlock()
+
+	t.seq++
+	heap.Push(&t.queue, &entry{item: item, seq: t.seq})
+
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+
+	if t.settings.MaxSize == 0 || t.queue.Len() <= t.settings.MaxSize {
+		return nil
+	}
+
+	// drop the least important item, which might be the one just pushed
+	worst := 0
+	for i := range t.queue {
+		if t.queue.Less(worst, i) {
+			worst = i
+		}
+	}
+	dropped := heap.Remove(&t.queue, worst).(*entry)
+	return &dropped.item
+}
+
+func (t *Component) pop() (PQItem, bool) {
+	t.queueLock.Lock()
+	defer t.queueLock.Unlock()
+
+	if t.queue.Len() == 0 {
+		return PQItem{}, false
+	}
+	return heap.Pop(&t.queue).(*entry).item, true
+}
+
+func (t *Component) size() int {
+	t.queueLock.Lock()
+	defer t.queueLock.Unlock()
+	return t.queue.Len()
+}
+
+func (t *Component) run(ctx context.Context, handler module.Handler) error {
+
+	t.runLock.Lock()
+	defer t.runLock.Unlock()
+
+	runCtx, runCancel := context.WithCancel(ctx)
+	defer runCancel()
+
+	t.setCancelFunc(runCancel)
+	// reconcile so show we are listening
+	_ = handler(context.Background(), module.ReconcilePort, nil)
+
+	defer func() {
+		t.setCancelFunc(nil)
+		_ = handler(context.Background(), module.ReconcilePort, nil)
+	}()
+
+	for {
+		item, ok := t.pop()
+		if !ok {
+			select {
+			case <-t.notify:
+				continue
+			case <-runCtx.Done():
+				return nil
+			}
+		}
+		// blocks until downstream is done
+		_ = handler(trace.ContextWithSpanContext(runCtx, trace.NewSpanContext(trace.SpanContextConfig{})), OutPort, item)
+
+		if runCtx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (t *Component) setCancelFunc(f func()) {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	t.cancelFunc = f
+}
+
+func (t *Component) isRunning() bool {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	return t.cancelFunc != nil
+}
+
+func (t *Component) stop() error {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	if t.cancelFunc == nil {
+		return nil
+	}
+	t.cancelFunc()
+	return nil
+}
+
+func (t *Component) getControl() interface{} {
+	if t.isRunning() {
+		return StopControl{
+			Status: "Running",
+			Size:   t.size(),
+		}
+	}
+	return StartControl{
+		Status: "Not running",
+		Size:   t.size(),
+	}
+}
+
+func (t *Component) Ports() []module.Port {
+	return []module.Port{
+		{
+			Name:          module.SettingsPort,
+			Label:         "Settings",
+			Source:        true,
+			Configuration: t.settings,
+		},
+		{
+			Name:          module.ControlPort,
+			Label:         "Dashboard",
+			Configuration: t.getControl(),
+		},
+		{
+			Name:          InPort,
+			Label:         "In",
+			Source:        true,
+			Configuration: PQItem{},
+			Position:      module.Left,
+		},
+		{
+			Name:          StartPort,
+			Label:         "Start",
+			Source:        true,
+			Configuration: Start{},
+			Position:      module.Left,
+		},
+		{
+			Name:          StopPort,
+			Label:         "Stop",
+			Source:        true,
+			Configuration: Stop{},
+			Position:      module.Bottom,
+		},
+		{
+			Name:          OutPort,
+			Label:         "Out",
+			Source:        false,
+			Configuration: PQItem{},
+			Position:      module.Right,
+		},
+		{
+			Name:          FullPort,
+			Label:         "Full",
+			Source:        false,
+			Configuration: QueueFull{},
+			Position:      module.Bottom,
+		},
+	}
+}
+
+var _ module.Component = (*Component)(nil)
+
+func init() {
+	registry.Register(&Component{})
+}
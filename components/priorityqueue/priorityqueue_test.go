@@ -0,0 +1,74 @@
+package priorityqueue
+
+import (
+	"context"
+	"github.com/tiny-systems/module/module"
+	"testing"
+	"time"
+)
+
+func TestComponent_PriorityOrder(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	for p := 5; p >= 1; p-- {
+		if err := c.Handle(context.Background(), nil, InPort, PQItem{Priority: p, Context: p}); err != nil {
+			t.Fatalf("enqueue error: %v", err)
+		}
+	}
+
+	var got []int
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port != OutPort {
+			return nil
+		}
+		got = append(got, data.(PQItem).Priority)
+		if len(got) == 5 {
+			return c.Handle(ctx, nil, StopPort, Stop{})
+		}
+		return nil
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- c.Handle(context.Background(), handler, StartPort, Start{})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queue was not drained")
+	}
+
+	for i, p := range got {
+		if p != i+1 {
+			t.Fatalf("items dequeued out of priority order: %v", got)
+		}
+	}
+}
+
+func TestComponent_Full(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{MaxSize: 2})
+
+	var dropped []PQItem
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == FullPort {
+			dropped = append(dropped, data.(QueueFull).Dropped)
+		}
+		return nil
+	}
+
+	for _, p := range []int{2, 3, 1} {
+		_ = c.Handle(context.Background(), handler, InPort, PQItem{Priority: p})
+	}
+
+	if len(dropped) != 1 || dropped[0].Priority != 3 {
+		t.Fatalf("expected item with priority 3 to be dropped, got %v", dropped)
+	}
+	if c.size() != 2 {
+		t.Errorf("expected queue size 2, got %d", c.size())
+	}
+}
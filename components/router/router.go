@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"github.com/goccy/go-json"
+	"github.com/spyzhov/ajson"
 	"github.com/swaggest/jsonschema-go"
 	"github.com/tiny-systems/module/module"
 	"github.com/tiny-systems/module/registry"
+	"hash/fnv"
 	"strings"
 )
 
@@ -14,6 +16,7 @@ const (
 	ComponentName = "router"
 	InPort        = "input"
 	DefaultPort   = "default"
+	AggregatePort = "aggregate"
 )
 
 // RouteName special type which can carry its value and possible options for enum values
@@ -52,15 +55,25 @@ type Condition struct {
 }
 
 type Settings struct {
-	Routes            []string `json:"routes" required:"true" title:"Routes" minItems:"1" uniqueItems:"true"`
-	EnableDefaultPort bool     `json:"enableDefaultPort" required:"true" title:"Enable default port"`
+	Routes              []string `json:"routes" required:"true" title:"Routes" minItems:"1" uniqueItems:"true"`
+	EnableDefaultPort   bool     `json:"enableDefaultPort" required:"true" title:"Enable default port"`
+	StickyMode          bool     `json:"stickyMode" title:"Sticky mode" description:"Messages with the same key always go to the same route, conditions are ignored. Messages without the key go to the default port if it is enabled"`
+	StickyKey           string   `json:"stickyKey" title:"Sticky key" description:"JSONPath of the key in the message context. Example: $.userID"`
+	EnableAggregatePort bool     `json:"enableAggregatePort" title:"Enable aggregate port" description:"Aggregate port receives every message together with the matched route, in addition to the route ports"`
 }
 
 type Context any
 
 type InMessage struct {
 	Context    Context     `json:"context" configurable:"true" required:"true" title:"Context" description:"Arbitrary message to be routed"`
-	Conditions []Condition `json:"conditions" required:"true" title:"Conditions" minItems:"1" uniqueItems:"true"`
+	Conditions []Condition `json:"conditions" required:"true" title:"Conditions" description:"Ignored in sticky mode, route is picked by the sticky key instead" minItems:"1" uniqueItems:"true"`
+}
+
+// AggregateMessage is sent for every incoming message when aggregate port is enabled
+type AggregateMessage struct {
+	Context      Context  `json:"context"`
+	MatchedRoute string   `json:"matchedRoute" title:"Matched route" description:"Route of the first true condition, empty if none matched"`
+	AllRoutes    []string `json:"allRoutes" title:"All routes"`
 }
 
 type Component struct {
@@ -92,6 +105,11 @@ func (t *Component) Handle(ctx context.Context, handler module.Handler, port str
 		if !ok {
 			return fmt.Errorf("invalid settings")
 		}
+		if in.StickyMode {
+			if _, err := ajson.ParseJSONPath(in.StickyKey); err != nil {
+				return fmt.Errorf("invalid sticky key: %v", err)
+			}
+		}
 		t.settings = in
 		return nil
 	}
@@ -101,15 +119,71 @@ func (t *Component) Handle(ctx context.Context, handler module.Handler, port str
 		return fmt.Errorf("invalid message")
 	}
 
-	for _, condition := range in.Conditions {
-		if condition.Condition {
-			return handler(ctx, getPortNameFromRoute(condition.RouteName.Value), in.Context)
+	var matched string
+	if t.settings.StickyMode {
+		route, err := t.stickyRoute(in.Context)
+		if err != nil {
+			return err
 		}
+		if route == "" && !t.settings.EnableDefaultPort {
+			return fmt.Errorf("sticky key %s not found", t.settings.StickyKey)
+		}
+		// messages without the key go to the default port
+		matched = route
+	} else {
+		for _, condition := range in.Conditions {
+			if condition.Condition {
+				matched = condition.RouteName.Value
+				break
+			}
+		}
+	}
+
+	if err := t.route(ctx, handler, matched, in.Context); err != nil {
+		return err
+	}
+	if !t.settings.EnableAggregatePort {
+		return nil
+	}
+	return handler(ctx, AggregatePort, AggregateMessage{
+		Context:      in.Context,
+		MatchedRoute: matched,
+		AllRoutes:    t.settings.Routes,
+	})
+}
+
+// stickyRoute picks route by hash of the sticky key, so the same key always goes to the same route.
+// Empty route is returned if the message has no sticky key
+func (t *Component) stickyRoute(msg Context) (string, error) {
+	if len(t.settings.Routes) == 0 {
+		return "", fmt.Errorf("no routes defined")
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode context: %v", err)
+	}
+	nodes, err := ajson.JSONPath(data, t.settings.StickyKey)
+	if err != nil {
+		return "", fmt.Errorf("unable to find sticky key: %v", err)
+	}
+	if len(nodes) == 0 {
+		return "", nil
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(nodes[0].String()))
+	return t.settings.Routes[h.Sum32()%uint32(len(t.settings.Routes))], nil
+}
+
+// route sends message to the matched route port or to the default port if nothing matched
+func (t *Component) route(ctx context.Context, handler module.Handler, route string, msg Context) error {
+	if route != "" {
+		return handler(ctx, getPortNameFromRoute(route), msg)
 	}
 	if !t.settings.EnableDefaultPort {
 		return nil
 	}
-	return handler(ctx, DefaultPort, in.Context)
+	return handler(ctx, DefaultPort, msg)
 }
 
 // Ports drop settings, make it port payload
@@ -160,6 +234,15 @@ func (t *Component) Ports() []module.Port {
 			Configuration: new(Context),
 		})
 	}
+	if t.settings.EnableAggregatePort {
+		ports = append(ports, module.Port{
+			Position:      module.Right,
+			Name:          AggregatePort,
+			Label:         "Aggregate",
+			Source:        false,
+			Configuration: AggregateMessage{},
+		})
+	}
 	return ports
 }
 
@@ -0,0 +1,116 @@
+package router
+
+import (
+	"context"
+	"github.com/tiny-systems/module/module"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestComponent_Aggregate(t *testing.T) {
+	c := (&Component{}).Instance()
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Routes:              []string{"A", "B", "C"},
+		EnableAggregatePort: true,
+	})
+
+	var (
+		ports      []string
+		aggregates []AggregateMessage
+	)
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		ports = append(ports, port)
+		if port == AggregatePort {
+			aggregates = append(aggregates, data.(AggregateMessage))
+		}
+		return nil
+	}
+
+	for _, route := range []string{"A", "B", "C"} {
+		err := c.Handle(context.Background(), handler, InPort, InMessage{
+			Context: route,
+			Conditions: []Condition{
+				{RouteName: RouteName{Value: "A"}, Condition: route == "A"},
+				{RouteName: RouteName{Value: "B"}, Condition: route == "B"},
+				{RouteName: RouteName{Value: "C"}, Condition: route == "C"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("route error: %v", err)
+		}
+	}
+
+	if want := []string{"out_a", AggregatePort, "out_b", AggregatePort, "out_c", AggregatePort}; !slices.Equal(ports, want) {
+		t.Errorf("expected ports %v, got %v", want, ports)
+	}
+	if len(aggregates) != 3 {
+		t.Fatalf("expected 3 aggregate messages, got %d", len(aggregates))
+	}
+	for i, route := range []string{"A", "B", "C"} {
+		msg := aggregates[i]
+		if msg.MatchedRoute != route || msg.Context != route || !slices.Equal(msg.AllRoutes, []string{"A", "B", "C"}) {
+			t.Errorf("unexpected aggregate message: %+v", msg)
+		}
+	}
+}
+
+func TestComponent_Sticky(t *testing.T) {
+	c := (&Component{}).Instance()
+	err := c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Routes:     []string{"A", "B", "C", "D"},
+		StickyMode: true,
+		StickyKey:  "$.user",
+	})
+	if err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+
+	route := func(user string) map[string]int {
+		ports := make(map[string]int)
+		for i := 0; i < 100; i++ {
+			_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+				ports[port]++
+				return nil
+			}, InPort, InMessage{
+				Context: map[string]interface{}{"user": user, "n": i},
+				// conditions are ignored in sticky mode
+				Conditions: []Condition{{RouteName: RouteName{Value: "A"}, Condition: true}},
+			})
+		}
+		return ports
+	}
+
+	first, second := route("alice"), route("bob")
+	if len(first) != 1 || len(second) != 1 {
+		t.Fatalf("same key should always reach the same route, got %v and %v", first, second)
+	}
+	if again := route("alice"); !maps.Equal(first, again) {
+		t.Errorf("route of the key changed: %v and %v", first, again)
+	}
+
+	err = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		return nil
+	}, InPort, InMessage{Context: map[string]interface{}{"id": 1}})
+	if err == nil {
+		t.Error("expected error for missing sticky key")
+	}
+
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Routes:            []string{"A", "B"},
+		StickyMode:        true,
+		StickyKey:         "$.user",
+		EnableDefaultPort: true,
+	})
+	var ports []string
+	err = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		ports = append(ports, port)
+		return nil
+	}, InPort, InMessage{Context: map[string]interface{}{"id": 1}})
+	if err != nil {
+		t.Fatalf("message without sticky key should go to default port, got %v", err)
+	}
+	if len(ports) != 1 || ports[0] != DefaultPort {
+		t.Errorf("expected message on default port, got %v", ports)
+	}
+}
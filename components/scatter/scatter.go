@@ -0,0 +1,98 @@
+package scatter
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tiny-systems/module/module"
+	"github.com/tiny-systems/module/registry"
+)
+
+const (
+	ComponentName        = "scatter"
+	OutPort       string = "out"
+	InPort        string = "in"
+)
+
+type Context any
+
+type ItemContext any
+
+type InMessage struct {
+	Context Context       `json:"context" title:"Context" configurable:"true" description:"Message to be send further with each item"`
+	Array   []ItemContext `json:"array" title:"Array" default:"null" description:"Array of items to be scattered" required:"true"`
+}
+
+type OutMessage struct {
+	ScatterID string      `json:"scatterID" title:"Scatter ID" description:"Unique ID shared by all items of the same incoming message"`
+	Total     int         `json:"total" title:"Total" description:"Number of items scattered"`
+	Index     int         `json:"index" title:"Index" description:"Position of the item in the original array"`
+	Context   Context     `json:"context"`
+	Item      ItemContext `json:"item"`
+}
+
+type Component struct {
+}
+
+func (t *Component) Instance() module.Component {
+	return &Component{}
+}
+
+func (t *Component) GetInfo() module.ComponentInfo {
+	return module.ComponentInfo{
+		Name:        ComponentName,
+		Description: "Scatter",
+		Info:        "Splits an array into separate messages tagged with a shared scatter ID and total count. Use together with Gather to collect results back.",
+		Tags:        []string{"SDK", "ARRAY"},
+	}
+}
+
+func (t *Component) Handle(ctx context.Context, handler module.Handler, port string, msg interface{}) error {
+	in, ok := msg.(InMessage)
+	if !ok {
+		return fmt.Errorf("invalid message")
+	}
+
+	var (
+		id    = uuid.NewString()
+		total = len(in.Array)
+	)
+
+	for i, item := range in.Array {
+		if err := handler(ctx, OutPort, OutMessage{
+			ScatterID: id,
+			Total:     total,
+			Index:     i,
+			Context:   in.Context,
+			Item:      item,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Component) Ports() []module.Port {
+	return []module.Port{
+		{
+			Name:          InPort,
+			Label:         "In",
+			Source:        true,
+			Configuration: InMessage{},
+			Position:      module.Left,
+		},
+		{
+			Name:          OutPort,
+			Label:         "Out",
+			Source:        false,
+			Configuration: OutMessage{},
+			Position:      module.Right,
+		},
+	}
+}
+
+var _ module.Component = (*Component)(nil)
+
+func init() {
+	registry.Register(&Component{})
+}
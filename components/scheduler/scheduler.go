@@ -7,6 +7,7 @@ import (
 	"github.com/tiny-systems/module/module"
 	"github.com/tiny-systems/module/registry"
 	"go.opentelemetry.io/otel/trace"
+	"sort"
 	"sync"
 	"time"
 )
@@ -18,11 +19,26 @@ const (
 	StartPort     string = "start"
 	AckPort       string = "ack"
 	StopPort      string = "stop"
+
+	ReschedulePort    string = "reschedule"
+	RescheduleAckPort string = "reschedule_ack"
+
+	BulkCancelPort       string = "bulk_cancel"
+	BulkCancelResultPort string = "bulk_cancel_result"
 )
 
 type Settings struct {
 	EnableAckPort  bool `json:"enableAckPort" title:"Enable task acknowledge port" description:"Port gives information if incoming task was scheduled properly"`
 	EnableStopPort bool `json:"enableStopPort" required:"true" title:"Enable stop port" description:"Stop port allows you to stop scheduler"`
+
+	EnableReschedulePort bool `json:"enableReschedulePort" title:"Enable reschedule port" description:"Reschedule port allows you to move existing task to a new date and time"`
+	EnableBulkCancelPort bool `json:"enableBulkCancelPort" title:"Enable bulk cancel port" description:"Bulk cancel port allows you to cancel many tasks with a single message"`
+}
+
+// ValidateSettings checks settings without delivering them to the component.
+// Every combination of scheduler ports is valid.
+func (s Settings) ValidateSettings() error {
+	return nil
 }
 
 type StartControl struct {
@@ -66,10 +82,46 @@ type TaskAck struct {
 	Error       *string `json:"error"`
 }
 
+type RescheduleRequest struct {
+	Context     Context   `json:"context" title:"Context" configurable:"true" description:"Arbitrary message to be send further"`
+	ID          string    `json:"id" required:"true" title:"Task ID"`
+	NewDateTime time.Time `json:"newDateTime" required:"true" title:"New date and time" description:"Format examples: 2012-10-01T09:45:00.000+02:00"`
+	Reason      string    `json:"reason" title:"Reason"`
+}
+
+type RescheduleAck struct {
+	Context     Context   `json:"context"`
+	ID          string    `json:"id"`
+	OldTime     time.Time `json:"oldTime"`
+	NewTime     time.Time `json:"newTime"`
+	ScheduledIn int64     `json:"scheduledIn"`
+	Reason      string    `json:"reason"`
+}
+
+type BulkCancelRequest struct {
+	Context Context  `json:"context" title:"Context" configurable:"true" description:"Arbitrary message to be send further"`
+	IDs     []string `json:"ids" title:"Task IDs" description:"Tasks to cancel. Empty list cancels all tasks"`
+}
+
+type BulkCancelResult struct {
+	Context   Context  `json:"context"`
+	Cancelled []string `json:"cancelled"`
+	NotFound  []string `json:"notFound"`
+}
+
 type task struct {
 	timer *time.Timer
-	call  func(ctx context.Context)
-	id    string
+	// done releases goroutine waiting for the task, stopped timer never fires
+	done chan struct{}
+	call func(ctx context.Context, at time.Time)
+	id   string
+	at   time.Time
+}
+
+// stop stops task timer and its waiting goroutine, task must be removed from tasks first
+func (t *task) stop() {
+	t.timer.Stop()
+	close(t.done)
 }
 
 type Component struct {
@@ -153,6 +205,9 @@ func (s *Component) Handle(ctx context.Context, handler module.Handler, port str
 		if !ok {
 			return fmt.Errorf("invalid settings")
 		}
+		if err := in.ValidateSettings(); err != nil {
+			return err
+		}
 		s.settings = in
 		return nil
 
@@ -187,9 +242,11 @@ func (s *Component) Handle(ctx context.Context, handler module.Handler, port str
 			scheduledIn = int64(t.DateTime.Sub(time.Now()).Seconds())
 		}
 
-		ackErr := s.addOrUpdateTask(t.ID, t.Schedule, t.DateTime.Sub(time.Now()), func(ctx context.Context) {
+		ackErr := s.addOrUpdateTask(t.ID, t.Schedule, t.DateTime, func(ctx context.Context, at time.Time) {
+			task := in.Task
+			task.DateTime = at
 			_ = handler(ctx, OutPort, OutMessage{
-				Task:    in.Task,
+				Task:    task,
 				Context: in.Context,
 			})
 		})
@@ -214,25 +271,87 @@ func (s *Component) Handle(ctx context.Context, handler module.Handler, port str
 
 		//
 
+	case ReschedulePort:
+		in, ok := msg.(RescheduleRequest)
+		if !ok {
+			return fmt.Errorf("invalid reschedule request")
+		}
+		if err := s.checkSchedule(in.NewDateTime); err != nil {
+			return err
+		}
+		// task which is already firing is not in the list anymore, so it can not fire twice
+		d, ok := s.tasks.Pop(in.ID)
+		if !ok {
+			return fmt.Errorf("task not found: %s", in.ID)
+		}
+		d.stop()
+		if err := s.addOrUpdateTask(in.ID, true, in.NewDateTime, d.call); err != nil {
+			return err
+		}
+		return handler(ctx, RescheduleAckPort, RescheduleAck{
+			Context:     in.Context,
+			ID:          in.ID,
+			OldTime:     d.at,
+			NewTime:     in.NewDateTime,
+			ScheduledIn: int64(in.NewDateTime.Sub(time.Now()).Seconds()),
+			Reason:      in.Reason,
+		})
+
+	case BulkCancelPort:
+		in, ok := msg.(BulkCancelRequest)
+		if !ok {
+			return fmt.Errorf("invalid bulk cancel request")
+		}
+
+		ids := in.IDs
+		if len(ids) == 0 {
+			// cancel all, tasks can not be removed while iterating
+			s.tasks.IterCb(func(id string, _ *task) {
+				ids = append(ids, id)
+			})
+			sort.Strings(ids)
+		}
+
+		result := BulkCancelResult{
+			Context:   in.Context,
+			Cancelled: []string{},
+			NotFound:  []string{},
+		}
+		for _, id := range ids {
+			if s.cancelTask(id) {
+				result.Cancelled = append(result.Cancelled, id)
+			} else {
+				result.NotFound = append(result.NotFound, id)
+			}
+		}
+		return handler(ctx, BulkCancelResultPort, result)
+
 	default:
 		return fmt.Errorf("invalid port: %s", port)
 	}
 	return nil
 }
 
-func (s *Component) addOrUpdateTask(id string, schedule bool, duration time.Duration, f func(ctx context.Context)) error {
-
+// checkSchedule checks if task can be scheduled at the given time
+func (s *Component) checkSchedule(at time.Time) error {
 	if !s.isRunning() {
 		return fmt.Errorf("scheduler is not running")
 	}
-	if duration.Seconds() < 0 {
+	if at.Sub(time.Now()).Seconds() < 0 {
 		return fmt.Errorf("scheduled time is past")
 	}
+	return nil
+}
+
+func (s *Component) addOrUpdateTask(id string, schedule bool, at time.Time, f func(ctx context.Context, at time.Time)) error {
+
+	if err := s.checkSchedule(at); err != nil {
+		return err
+	}
 
-	if d, ok := s.tasks.Get(id); ok {
+	if d, ok := s.tasks.Pop(id); ok {
 		// stop and remove it
-		d.timer.Stop()
-		s.tasks.Remove(id)
+		d.stop()
 	}
 	// not found and don't ask to schedule
 	if !schedule {
@@ -241,9 +360,11 @@ func (s *Component) addOrUpdateTask(id string, schedule bool, duration time.Dura
 
 	// schedule a new task
 	tt := &task{
-		timer: time.NewTimer(duration),
+		timer: time.NewTimer(at.Sub(time.Now())),
+		done:  make(chan struct{}),
 		id:    id,
 		call:  f,
+		at:    at,
 	}
 
 	s.tasks.Set(id, tt)
@@ -251,14 +372,36 @@ func (s *Component) addOrUpdateTask(id string, schedule bool, duration time.Dura
 	return nil
 }
 
+// cancelTask stops task and removes it, returns false if task not found
+func (s *Component) cancelTask(id string) bool {
+	d, ok := s.tasks.Pop(id)
+	if !ok {
+		return false
+	}
+	d.stop()
+	return true
+}
+
 func (s *Component) waitTask(d *task) {
 
-	defer s.tasks.Remove(d.id)
+	// task may have been replaced already
+	remove := func() bool {
+		return s.tasks.RemoveCb(d.id, func(_ string, v *task, exists bool) bool {
+			return exists && v == d
+		})
+	}
 	select {
 	case <-d.timer.C:
+		// task is removed before it fires, so it can not be rescheduled while firing
+		if !remove() {
+			// replaced or cancelled right after timer fired
+			return
+		}
 		// new trace
-		d.call(trace.ContextWithSpanContext(s.runCtx, trace.NewSpanContext(trace.SpanContextConfig{})))
+		d.call(trace.ContextWithSpanContext(s.runCtx, trace.NewSpanContext(trace.SpanContextConfig{})), d.at)
+	case <-d.done:
 	case <-s.runCtx.Done():
+		remove()
 	}
 }
 
@@ -326,6 +469,41 @@ func (s *Component) Ports() []module.Port {
 		})
 	}
 
+	if s.settings.EnableReschedulePort {
+		ports = append(ports, module.Port{
+			Name:   ReschedulePort,
+			Label:  "Reschedule",
+			Source: true,
+			Configuration: RescheduleRequest{
+				ID:          "someUniqueID",
+				NewDateTime: time.Now(),
+			},
+			Position: module.Left,
+		}, module.Port{
+			Name:          RescheduleAckPort,
+			Label:         "Reschedule ack",
+			Source:        false,
+			Configuration: RescheduleAck{},
+			Position:      module.Right,
+		})
+	}
+
+	if s.settings.EnableBulkCancelPort {
+		ports = append(ports, module.Port{
+			Name:          BulkCancelPort,
+			Label:         "Bulk cancel",
+			Source:        true,
+			Configuration: BulkCancelRequest{},
+			Position:      module.Left,
+		}, module.Port{
+			Name:          BulkCancelResultPort,
+			Label:         "Bulk cancel result",
+			Source:        false,
+			Configuration: BulkCancelResult{},
+			Position:      module.Right,
+		})
+	}
+
 	if !s.settings.EnableAckPort {
 		return ports
 	}
@@ -0,0 +1,269 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"github.com/tiny-systems/common-module/testharness"
+	"github.com/tiny-systems/module/module"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func noop(ctx context.Context, port string, data interface{}) error {
+	return nil
+}
+
+// start runs scheduler in background and waits until it accepts tasks
+func start(ctx context.Context, c *Component, handler module.Handler) {
+	go func() {
+		_ = c.Handle(ctx, handler, StartPort, Start{})
+	}()
+	for !c.isRunning() {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestComponent_Reschedule(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableReschedulePort: true})
+
+	fired := make(chan OutMessage, 1)
+	var ack RescheduleAck
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		switch port {
+		case OutPort:
+			fired <- data.(OutMessage)
+		case RescheduleAckPort:
+			ack = data.(RescheduleAck)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start(ctx, c, handler)
+
+	oldTime := time.Now().Add(100 * time.Millisecond)
+	if err := c.Handle(ctx, handler, InPort, InMessage{
+		Task: Task{ID: "task", DateTime: oldTime, Schedule: true},
+	}); err != nil {
+		t.Fatalf("schedule error: %v", err)
+	}
+
+	newTime := time.Now().Add(500 * time.Millisecond)
+	if err := c.Handle(ctx, handler, ReschedulePort, RescheduleRequest{ID: "task", NewDateTime: newTime, Reason: "moved"}); err != nil {
+		t.Fatalf("reschedule error: %v", err)
+	}
+	if !ack.OldTime.Equal(oldTime) || !ack.NewTime.Equal(newTime) || ack.Reason != "moved" {
+		t.Errorf("unexpected ack: %+v", ack)
+	}
+
+	select {
+	case msg := <-fired:
+		if time.Now().Before(newTime) {
+			t.Errorf("task fired before the new time")
+		}
+		if !msg.Task.DateTime.Equal(newTime) {
+			t.Errorf("task date time was not updated: %v", msg.Task.DateTime)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("rescheduled task did not fire")
+	}
+
+	if err := c.Handle(ctx, handler, ReschedulePort, RescheduleRequest{ID: "missing", NewDateTime: newTime}); err == nil {
+		t.Error("expected error for unknown task")
+	}
+}
+
+func TestComponent_RescheduleWhileFiring(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableReschedulePort: true})
+
+	var (
+		firing  = make(chan struct{})
+		release = make(chan struct{})
+		fired   = make(chan struct{}, 2)
+	)
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			fired <- struct{}{}
+			firing <- struct{}{}
+			<-release
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start(ctx, c, handler)
+
+	if err := c.Handle(ctx, handler, InPort, InMessage{
+		Task: Task{ID: "task", DateTime: time.Now().Add(10 * time.Millisecond), Schedule: true},
+	}); err != nil {
+		t.Fatalf("schedule error: %v", err)
+	}
+	<-firing
+
+	err := c.Handle(ctx, handler, ReschedulePort, RescheduleRequest{ID: "task", NewDateTime: time.Now().Add(50 * time.Millisecond)})
+	close(release)
+	if err == nil {
+		t.Error("expected error rescheduling task which is already firing")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if len(fired) != 1 {
+		t.Errorf("expected task to fire once, fired %d times", len(fired))
+	}
+}
+
+// waitGoroutines waits until number of goroutines drops to n or less, returns the last count
+func waitGoroutines(n int) int {
+	count := runtime.NumGoroutine()
+	for i := 0; i < 100 && count > n; i++ {
+		time.Sleep(10 * time.Millisecond)
+		count = runtime.NumGoroutine()
+	}
+	return count
+}
+
+func TestComponent_RescheduleReleasesGoroutine(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableReschedulePort: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start(ctx, c, noop)
+
+	_ = c.Handle(ctx, noop, InPort, InMessage{
+		Task: Task{ID: "task", DateTime: time.Now().Add(time.Hour), Schedule: true},
+	})
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 100; i++ {
+		if err := c.Handle(ctx, noop, ReschedulePort, RescheduleRequest{ID: "task", NewDateTime: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatalf("reschedule error: %v", err)
+		}
+	}
+	if count := waitGoroutines(before); count > before {
+		t.Errorf("rescheduled tasks left %d goroutines waiting", count-before)
+	}
+	if !c.tasks.Has("task") {
+		t.Error("replaced task goroutine removed the new task")
+	}
+}
+
+func TestComponent_BulkCancel(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableBulkCancelPort: true})
+
+	fired := make(chan string, 5)
+	var result BulkCancelResult
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		switch port {
+		case OutPort:
+			fired <- data.(OutMessage).Task.ID
+		case BulkCancelResultPort:
+			result = data.(BulkCancelResult)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start(ctx, c, handler)
+
+	at := time.Now().Add(100 * time.Millisecond)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if err := c.Handle(ctx, handler, InPort, InMessage{
+			Task: Task{ID: id, DateTime: at, Schedule: true},
+		}); err != nil {
+			t.Fatalf("schedule error: %v", err)
+		}
+	}
+
+	if err := c.Handle(ctx, handler, BulkCancelPort, BulkCancelRequest{IDs: []string{"a", "c", "e", "x"}}); err != nil {
+		t.Fatalf("bulk cancel error: %v", err)
+	}
+	if len(result.Cancelled) != 3 || len(result.NotFound) != 1 || result.NotFound[0] != "x" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	got := map[string]bool{}
+	timeout := time.After(time.Second)
+	for len(got) < 2 {
+		select {
+		case id := <-fired:
+			got[id] = true
+		case <-timeout:
+			t.Fatalf("remaining tasks did not fire, got %v", got)
+		}
+	}
+	if !got["b"] || !got["d"] {
+		t.Errorf("unexpected tasks fired: %v", got)
+	}
+
+	select {
+	case id := <-fired:
+		t.Errorf("cancelled task %s fired", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestComponent_BulkCancelAll(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableBulkCancelPort: true})
+
+	var result BulkCancelResult
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == BulkCancelResultPort {
+			result = data.(BulkCancelResult)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start(ctx, c, handler)
+
+	for _, id := range []string{"b", "a"} {
+		_ = c.Handle(ctx, handler, InPort, InMessage{
+			Task: Task{ID: id, DateTime: time.Now().Add(time.Minute), Schedule: true},
+		})
+	}
+	_ = c.Handle(ctx, handler, BulkCancelPort, BulkCancelRequest{})
+
+	if len(result.Cancelled) != 2 || result.Cancelled[0] != "a" || c.tasks.Count() != 0 {
+		t.Errorf("expected all tasks to be cancelled: %+v", result)
+	}
+}
+
+func TestComponent_BulkCancelReleasesGoroutines(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableBulkCancelPort: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start(ctx, c, noop)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		_ = c.Handle(ctx, noop, InPort, InMessage{
+			Task: Task{ID: fmt.Sprintf("task-%d", i), DateTime: time.Now().Add(time.Hour), Schedule: true},
+		})
+	}
+	if err := c.Handle(ctx, noop, BulkCancelPort, BulkCancelRequest{}); err != nil {
+		t.Fatalf("bulk cancel error: %v", err)
+	}
+	if count := waitGoroutines(before); count > before {
+		t.Errorf("cancelled tasks left %d goroutines waiting", count-before)
+	}
+}
+
+func TestScheduler_ValidateSettings(t *testing.T) {
+	testharness.AssertValidSettings(t, (&Component{}).Instance())
+
+	if err := (Settings{}).ValidateSettings(); err != nil {
+		t.Errorf("expected zero value settings to be valid: %v", err)
+	}
+}
@@ -2,14 +2,27 @@ package signal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/tiny-systems/module/module"
 	"github.com/tiny-systems/module/registry"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	ComponentName        = "signal"
 	OutPort       string = "out"
+	TriggerPort   string = "trigger"
+	DonePort      string = "done"
+)
+
+const (
+	PastSendAtReject = "reject"
+	PastSendAtSend   = "send"
 )
 
 type Context any
@@ -17,20 +30,92 @@ type Context any
 type Settings struct {
 	Context Context `json:"context" required:"true" configurable:"true" title:"Context" description:"Arbitrary message to send"`
 	Auto    bool    `json:"auto" title:"Auto start" required:"true" description:"Start sending as soon as component deployed"`
+
+	RepeatCount      int `json:"repeatCount" title:"Repeat count" description:"Total number of messages to send in auto mode. Zero means once" minimum:"0" default:"0"`
+	RepeatIntervalMs int `json:"repeatIntervalMs" title:"Repeat interval (ms)" description:"Delay between repeated messages" minimum:"0" default:"0"`
+	AutoDelayMs      int `json:"autoDelayMs" title:"Auto start delay (ms)" description:"Wait before sending in auto mode, so other components have time to start" minimum:"0" default:"0"`
+
+	SendAt     string `json:"sendAt" format:"date-time" title:"Send at" description:"Send once at the given time (RFC3339) instead of auto start"`
+	PastSendAt string `json:"pastSendAt" enum:"reject,send" enumTitles:"Reject,Send immediately" title:"Past send at" description:"What to do if send at time has already passed. Reject returns error, send sends immediately. Rejected by default"`
+
+	WrapOutput bool `json:"wrapOutput" title:"Wrap output" description:"Send context together with the message which triggered it"`
+
+	EnableDonePort bool `json:"enableDonePort" title:"Enable done port" description:"Done port receives a message after each send attempt"`
+
+	Signals           []SignalStep `json:"signals,omitempty" title:"Signals" description:"Sequence of messages sent one after another instead of the context. Sequence is aborted if a message was not handled successfully"`
+	EnableSignalPorts bool         `json:"enableSignalPorts" title:"Enable signal ports" description:"Each signal of the sequence is sent to its own port instead of out port"`
+}
+
+type SignalStep struct {
+	Name    string  `json:"name" required:"true" title:"Name"`
+	Context Context `json:"context" configurable:"true" title:"Context" description:"Arbitrary message to send"`
+	DelayMs int     `json:"delayMs" title:"Delay (ms)" description:"Delay before sending the message" minimum:"0" default:"0"`
+}
+
+// Done confirms the message was sent
+type Done struct {
+	Context Context   `json:"context"`
+	SentAt  time.Time `json:"sentAt" title:"Sent at"`
+	Error   string    `json:"error,omitempty" title:"Error" description:"Error returned by the receiver, empty if the message was handled"`
+}
+
+type TriggerMessage struct {
+	Context Context `json:"context" configurable:"true" title:"Context" description:"Arbitrary message triggering the signal"`
+}
+
+// OutMessage is sent instead of the bare context when output is wrapped
+type OutMessage struct {
+	Signal         Context `json:"signal"`
+	TriggerContext Context `json:"triggerContext,omitempty" description:"Message received by trigger port, empty if signal was sent otherwise"`
 }
 
 type Component struct {
 	settings Settings
+
+	cancelFunc     context.CancelFunc
+	cancelFuncLock *sync.Mutex
+	// run identifies the latest repeating, sent and total show its progress
+	run   int
+	sent  int
+	total int
+	// step is the name of the signal being sent by the sequence
+	step string
+	// sendAt is the time scheduled send happens at
+	sendAt time.Time
+	// sentAt is the last send at setting which was sent
+	sentAt string
+
+	triggerCount atomic.Int64
+	// sentCount and lastSentAt track messages handled without error
+	sentCount  int64
+	lastSentAt time.Time
 }
 
 type Control struct {
-	Context Context `json:"context" required:"true" title:"Context"`
-	Send    bool    `json:"send" format:"button" title:"Send" required:"true"`
+	Context          Context `json:"context" required:"true" title:"Context"`
+	RepeatCount      int     `json:"repeatCount" title:"Repeat count" description:"Number of messages to send. Zero means once" minimum:"0"`
+	RepeatIntervalMs int     `json:"repeatIntervalMs" title:"Repeat interval (ms)" description:"Delay between repeated messages" minimum:"0"`
+	SendAt           string  `json:"sendAt" format:"date-time" title:"Send at" description:"Schedule sending at the given time (RFC3339) instead of sending immediately"`
+	Send             bool    `json:"send" format:"button" title:"Send" required:"true"`
+	TriggerCount     int64   `json:"triggerCount" readonly:"true" title:"Trigger count" description:"Number of messages received by trigger port"`
+	SentCount        int64   `json:"sentCount" readonly:"true" title:"Sent count" description:"Number of messages handled without error"`
+	LastSentAt       string  `json:"lastSentAt,omitempty" readonly:"true" title:"Last sent at"`
+}
+
+// SendingControl is shown while messages are repeated
+type SendingControl struct {
+	Context      Context `json:"context" readonly:"true" title:"Context"`
+	Status       string  `json:"status" readonly:"true" title:"Status"`
+	Stop         bool    `json:"stop" format:"button" title:"Stop" required:"true" description:"Stop repeating"`
+	TriggerCount int64   `json:"triggerCount" readonly:"true" title:"Trigger count" description:"Number of messages received by trigger port"`
+	SentCount    int64   `json:"sentCount" readonly:"true" title:"Sent count" description:"Number of messages handled without error"`
+	LastSentAt   string  `json:"lastSentAt,omitempty" readonly:"true" title:"Last sent at"`
 }
 
 func (t *Component) Instance() module.Component {
 	return &Component{
-		settings: Settings{},
+		settings:       Settings{},
+		cancelFuncLock: &sync.Mutex{},
 	}
 }
 
@@ -47,53 +132,462 @@ func (t *Component) Handle(ctx context.Context, handler module.Handler, port str
 
 	switch port {
 	case module.ControlPort:
-		in, ok := msg.(Control)
-		if !ok {
+		switch in := msg.(type) {
+		case SendingControl:
+			if in.Stop {
+				t.stop()
+				_ = handler(ctx, module.ReconcilePort, nil)
+			}
+			return nil
+
+		case Control:
+			t.settings.Context = in.Context
+			t.settings.RepeatCount = in.RepeatCount
+			t.settings.RepeatIntervalMs = in.RepeatIntervalMs
+
+			if in.SendAt == "" {
+				t.manual(ctx, handler, t.settings)
+				return nil
+			}
+			sendAt, err := checkSendAt(in.SendAt, t.settings.PastSendAt)
+			if err != nil {
+				return err
+			}
+			t.stop()
+			// scheduled send outlives control request
+			ctx = context.WithoutCancel(ctx)
+			settings := t.settings
+			t.schedule(ctx, handler, sendAt, func() {
+				t.manual(ctx, handler, settings)
+			})
+
+		default:
 			return fmt.Errorf("invalid input msg")
 		}
 
-		t.settings.Context = in.Context
+	case TriggerPort:
+		in, ok := msg.(TriggerMessage)
+		if !ok {
+			return fmt.Errorf("invalid trigger message")
+		}
+		t.triggerCount.Add(1)
+		err := t.send(ctx, handler, t.settings, OutPort, t.settings.Context, in.Context)
+		// show trigger and sent count
 		_ = handler(ctx, module.ReconcilePort, nil)
-		_ = handler(ctx, OutPort, in.Context)
+		return err
 
 	case module.SettingsPort:
 		in, ok := msg.(Settings)
 		if !ok {
 			return fmt.Errorf("invalid settings")
 		}
+		names := make(map[string]bool, len(in.Signals))
+		for _, step := range in.Signals {
+			if step.Name == "" {
+				return fmt.Errorf("signal name can not be empty")
+			}
+			// names become port names
+			key := strings.ToLower(step.Name)
+			if in.EnableSignalPorts && names[key] {
+				return fmt.Errorf("duplicate signal name: %s", step.Name)
+			}
+			names[key] = true
+		}
+		if in.SendAt != "" {
+			if _, err := time.Parse(time.RFC3339, in.SendAt); err != nil {
+				return fmt.Errorf("invalid send at: %v", err)
+			}
+		}
+		switch in.PastSendAt {
+		case "", PastSendAtReject, PastSendAtSend:
+		default:
+			return fmt.Errorf("unknown past send at mode: %s", in.PastSendAt)
+		}
 		t.settings = in
+		t.stop()
 
-		if t.settings.Auto {
-			return handler(ctx, OutPort, in.Context)
+		if in.SendAt != "" {
+			return t.at(ctx, handler, in)
 		}
+		if !t.settings.Auto {
+			return nil
+		}
+		if in.AutoDelayMs > 0 {
+			// do not block settings delivery while waiting
+			t.schedule(ctx, handler, time.Now().Add(time.Duration(in.AutoDelayMs)*time.Millisecond), func() {
+				_ = t.auto(ctx, handler, in)
+			})
+			return nil
+		}
+		return t.auto(ctx, handler, in)
+	}
+	return nil
+}
+
+// auto sends the sequence, repeated or single context in auto mode
+func (t *Component) auto(ctx context.Context, handler module.Handler, settings Settings) error {
+	if len(settings.Signals) > 0 {
+		t.sequence(ctx, handler, settings)
+		return nil
+	}
+	if settings.repeats() {
+		// do not block settings delivery while repeating
+		t.repeat(ctx, handler, settings)
+		return nil
+	}
+	err := t.send(ctx, handler, settings, OutPort, settings.Context, nil)
+	_ = handler(context.Background(), module.ReconcilePort, nil)
+	return err
+}
+
+// manual sends the sequence, repeated or single context requested by control
+func (t *Component) manual(ctx context.Context, handler module.Handler, settings Settings) {
+	if len(settings.Signals) > 0 || settings.repeats() {
+		t.stop()
+		// sending outlives control request
+		_ = t.auto(context.WithoutCancel(ctx), handler, settings)
+		return
+	}
+	_ = t.send(ctx, handler, settings, OutPort, settings.Context, nil)
+	_ = handler(ctx, module.ReconcilePort, nil)
+}
+
+// at schedules auto mode sending at send at time, once per send at value
+func (t *Component) at(ctx context.Context, handler module.Handler, settings Settings) error {
+	t.cancelFuncLock.Lock()
+	sent := t.sentAt == settings.SendAt
+	t.cancelFuncLock.Unlock()
+	if sent {
+		return nil
+	}
+
+	sendAt, err := checkSendAt(settings.SendAt, settings.PastSendAt)
+	if err != nil {
+		return err
 	}
+	t.schedule(ctx, handler, sendAt, func() {
+		t.cancelFuncLock.Lock()
+		t.sentAt = settings.SendAt
+		t.cancelFuncLock.Unlock()
+		_ = t.auto(ctx, handler, settings)
+	})
 	return nil
 }
 
+// checkSendAt parses send at time, past time is an error unless it should be sent immediately
+func checkSendAt(value string, pastSendAt string) (time.Time, error) {
+	sendAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return sendAt, fmt.Errorf("invalid send at: %v", err)
+	}
+	if pastSendAt != PastSendAtSend && !sendAt.After(time.Now()) {
+		return sendAt, fmt.Errorf("send at %s is in the past", value)
+	}
+	return sendAt, nil
+}
+
+// schedule calls send at the given time in background, control shows when it sends meanwhile
+func (t *Component) schedule(ctx context.Context, handler module.Handler, sendAt time.Time, send func()) {
+	runCtx, runCancel := context.WithCancel(ctx)
+	run := t.start(runCancel, 0)
+	t.countdown(run, sendAt)
+	_ = handler(context.Background(), module.ReconcilePort, nil)
+
+	go func() {
+		defer runCancel()
+
+		timer := time.NewTimer(time.Until(sendAt))
+		defer timer.Stop()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+	wait:
+		for {
+			select {
+			case <-ticker.C:
+				if time.Until(sendAt) < time.Minute {
+					// refresh countdown
+					_ = handler(context.Background(), module.ReconcilePort, nil)
+				}
+			case <-timer.C:
+				break wait
+			case <-runCtx.Done():
+				return
+			}
+		}
+		if !t.finish(run) {
+			return
+		}
+		_ = handler(context.Background(), module.ReconcilePort, nil)
+		send()
+	}()
+}
+
+// countdown sets the time scheduled send happens at
+func (t *Component) countdown(run int, sendAt time.Time) {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	if run == t.run {
+		t.sendAt = sendAt
+	}
+}
+
+// repeats reports if more than one message is sent
+func (s Settings) repeats() bool {
+	return s.RepeatCount > 1 && s.RepeatIntervalMs > 0
+}
+
+// send sends signal to out port, counts delivered messages, reports the result to done port if enabled
+func (t *Component) send(ctx context.Context, handler module.Handler, s Settings, port string, signal Context, trigger Context) error {
+	err := handler(ctx, port, s.output(signal, trigger))
+	if err == nil {
+		t.cancelFuncLock.Lock()
+		t.sentCount++
+		t.lastSentAt = time.Now()
+		t.cancelFuncLock.Unlock()
+	}
+	if !s.EnableDonePort {
+		return err
+	}
+
+	done := Done{
+		Context: signal,
+		SentAt:  time.Now(),
+	}
+	if err != nil {
+		done.Error = err.Error()
+	}
+	return errors.Join(err, handler(ctx, DonePort, done))
+}
+
+// output wraps signal together with the trigger message if needed
+func (s Settings) output(signal Context, trigger Context) interface{} {
+	if !s.WrapOutput {
+		return signal
+	}
+	return OutMessage{
+		Signal:         signal,
+		TriggerContext: trigger,
+	}
+}
+
+// repeat sends context RepeatCount times in background until stopped
+func (t *Component) repeat(ctx context.Context, handler module.Handler, settings Settings) {
+	runCtx, runCancel := context.WithCancel(ctx)
+	run := t.start(runCancel, settings.RepeatCount)
+	// show stop button
+	_ = handler(context.Background(), module.ReconcilePort, nil)
+
+	go func() {
+		defer func() {
+			runCancel()
+			if t.finish(run) {
+				_ = handler(context.Background(), module.ReconcilePort, nil)
+			}
+		}()
+
+		interval := time.Duration(settings.RepeatIntervalMs) * time.Millisecond
+		for i := 0; i < settings.RepeatCount; i++ {
+			if i > 0 {
+				select {
+				case <-time.After(interval):
+				case <-runCtx.Done():
+					return
+				}
+			}
+			_ = t.send(runCtx, handler, settings, OutPort, settings.Context, nil)
+			if t.progress(run, i+1, "") {
+				_ = handler(context.Background(), module.ReconcilePort, nil)
+			}
+		}
+	}()
+}
+
+// sequence sends signals one after another in background, stops on the first error
+func (t *Component) sequence(ctx context.Context, handler module.Handler, settings Settings) {
+	runCtx, runCancel := context.WithCancel(ctx)
+	run := t.start(runCancel, len(settings.Signals))
+
+	go func() {
+		defer func() {
+			runCancel()
+			if t.finish(run) {
+				_ = handler(context.Background(), module.ReconcilePort, nil)
+			}
+		}()
+
+		for i, step := range settings.Signals {
+			if !t.progress(run, i, step.Name) {
+				return
+			}
+			// show current step
+			_ = handler(context.Background(), module.ReconcilePort, nil)
+
+			if step.DelayMs > 0 {
+				select {
+				case <-time.After(time.Duration(step.DelayMs) * time.Millisecond):
+				case <-runCtx.Done():
+					return
+				}
+			}
+
+			port := OutPort
+			if settings.EnableSignalPorts {
+				port = getSignalPort(step.Name)
+			}
+			if err := t.send(runCtx, handler, settings, port, step.Context, nil); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func getSignalPort(name string) string {
+	return fmt.Sprintf("out_%s", strings.ToLower(name))
+}
+
+// start registers new repeating, returns its id
+func (t *Component) start(f context.CancelFunc, total int) int {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	t.run++
+	t.cancelFunc = f
+	t.sent = 0
+	t.total = total
+	t.step = ""
+	t.sendAt = time.Time{}
+	return t.run
+}
+
+// progress updates number of sent messages and current step, reports false if repeating was replaced by a new one
+func (t *Component) progress(run int, sent int, step string) bool {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	if run != t.run {
+		return false
+	}
+	t.sent = sent
+	t.step = step
+	return true
+}
+
+// finish forgets repeating if it is still the latest one
+func (t *Component) finish(run int) bool {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	if run != t.run || t.cancelFunc == nil {
+		return false
+	}
+	t.cancelFunc = nil
+	return true
+}
+
+// getControl shows stop button and progress while repeating
+func (t *Component) getControl() interface{} {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+
+	if t.cancelFunc != nil {
+		status := fmt.Sprintf("%d/%d sent", t.sent, t.total)
+		if t.step != "" {
+			status = fmt.Sprintf("Step %d/%d: %s", t.sent+1, t.total, t.step)
+		}
+		if !t.sendAt.IsZero() {
+			status = fmt.Sprintf("Sending in %ds", int(math.Ceil(time.Until(t.sendAt).Seconds())))
+			if time.Until(t.sendAt) > time.Minute {
+				status = fmt.Sprintf("Sending at %s", t.sendAt.Format(time.RFC3339))
+			}
+		}
+		return SendingControl{
+			Context:      t.settings.Context,
+			Status:       status,
+			TriggerCount: t.triggerCount.Load(),
+			SentCount:    t.sentCount,
+			LastSentAt:   t.getLastSentAt(),
+		}
+	}
+	return Control{
+		Context:          t.settings.Context,
+		RepeatCount:      t.settings.RepeatCount,
+		RepeatIntervalMs: t.settings.RepeatIntervalMs,
+		TriggerCount:     t.triggerCount.Load(),
+		SentCount:        t.sentCount,
+		LastSentAt:       t.getLastSentAt(),
+	}
+}
+
+// getLastSentAt formats last sent time, empty if nothing was sent, callers hold the lock
+func (t *Component) getLastSentAt() string {
+	if t.lastSentAt.IsZero() {
+		return ""
+	}
+	return t.lastSentAt.Format(time.RFC3339)
+}
+
+func (t *Component) stop() {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	if t.cancelFunc == nil {
+		return
+	}
+	t.cancelFunc()
+	t.cancelFunc = nil
+}
+
 func (t *Component) Ports() []module.Port {
+	var out interface{} = new(Context)
+	if t.settings.WrapOutput {
+		out = OutMessage{}
+	}
 
-	return []module.Port{
+	ports := []module.Port{
 		{
 			Name:          module.SettingsPort,
 			Label:         "Settings",
 			Source:        true,
 			Configuration: t.settings,
 		},
+		{
+			Name:          TriggerPort,
+			Label:         "Trigger",
+			Source:        true,
+			Position:      module.Left,
+			Configuration: TriggerMessage{},
+		},
 		{
 			Name:          OutPort,
 			Label:         "Out",
 			Source:        false,
 			Position:      module.Right,
-			Configuration: new(Context),
+			Configuration: out,
 		},
 		{
-			Name:  module.ControlPort,
-			Label: "Control",
-			Configuration: Control{
-				Context: t.settings.Context,
-			},
+			Name:          module.ControlPort,
+			Label:         "Control",
+			Configuration: t.getControl(),
 		},
 	}
+	if t.settings.EnableSignalPorts {
+		for _, step := range t.settings.Signals {
+			ports = append(ports, module.Port{
+				Name:          getSignalPort(step.Name),
+				Label:         step.Name,
+				Source:        false,
+				Position:      module.Right,
+				Configuration: out,
+			})
+		}
+	}
+	if t.settings.EnableDonePort {
+		ports = append(ports, module.Port{
+			Name:          DonePort,
+			Label:         "Done",
+			Source:        false,
+			Position:      module.Bottom,
+			Configuration: Done{},
+		})
+	}
+	return ports
 }
 
 var _ module.Component = (*Component)(nil)
@@ -0,0 +1,381 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/tiny-systems/module/module"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestComponent_Repeat(t *testing.T) {
+	c := (&Component{}).Instance()
+
+	var sent atomic.Int32
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			sent.Add(1)
+		}
+		return nil
+	}
+
+	err := c.Handle(context.Background(), handler, module.SettingsPort, Settings{
+		Context:          "ping",
+		Auto:             true,
+		RepeatCount:      3,
+		RepeatIntervalMs: 100,
+	})
+	if err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	if n := sent.Load(); n != 3 {
+		t.Errorf("expected 3 messages, got %d", n)
+	}
+}
+
+func TestComponent_RepeatStop(t *testing.T) {
+	c := (&Component{}).Instance()
+
+	var sent atomic.Int32
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			sent.Add(1)
+		}
+		return nil
+	}
+
+	_ = c.Handle(context.Background(), handler, module.SettingsPort, Settings{
+		Auto:             true,
+		RepeatCount:      10,
+		RepeatIntervalMs: 50,
+	})
+	time.Sleep(20 * time.Millisecond)
+	_ = c.Handle(context.Background(), handler, module.ControlPort, SendingControl{Stop: true})
+
+	time.Sleep(100 * time.Millisecond)
+	if n := sent.Load(); n != 1 {
+		t.Errorf("expected repeating to stop after first message, got %d", n)
+	}
+}
+
+func TestComponent_Trigger(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{Context: "signal", WrapOutput: true})
+
+	var out []OutMessage
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			out = append(out, data.(OutMessage))
+		}
+		return nil
+	}
+	for i := 0; i < 5; i++ {
+		if err := c.Handle(context.Background(), handler, TriggerPort, TriggerMessage{Context: i}); err != nil {
+			t.Fatalf("trigger error: %v", err)
+		}
+	}
+
+	if len(out) != 5 {
+		t.Fatalf("expected 5 messages, got %d", len(out))
+	}
+	for i, msg := range out {
+		if msg.Signal != "signal" || msg.TriggerContext != i {
+			t.Errorf("unexpected message: %+v", msg)
+		}
+	}
+	for _, port := range c.Ports() {
+		if port.Name != module.ControlPort {
+			continue
+		}
+		if n := port.Configuration.(Control).TriggerCount; n != 5 {
+			t.Errorf("expected trigger count 5, got %d", n)
+		}
+	}
+}
+
+func TestComponent_SendRepeat(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	var sent atomic.Int32
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			sent.Add(1)
+		}
+		return nil
+	}
+
+	err := c.Handle(context.Background(), handler, module.ControlPort, Control{
+		Context:          "ping",
+		RepeatCount:      3,
+		RepeatIntervalMs: 50,
+		Send:             true,
+	})
+	if err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+
+	time.Sleep(75 * time.Millisecond)
+	control, ok := c.getControl().(SendingControl)
+	if !ok {
+		t.Fatalf("expected stop button while sending, got %T", c.getControl())
+	}
+	if control.Status != "2/3 sent" {
+		t.Errorf("unexpected status: %s", control.Status)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if n := sent.Load(); n != 3 {
+		t.Errorf("expected 3 messages, got %d", n)
+	}
+	if _, ok := c.getControl().(Control); !ok {
+		t.Errorf("expected send button after sending, got %T", c.getControl())
+	}
+}
+
+func TestComponent_Done(t *testing.T) {
+	c := (&Component{}).Instance()
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableDonePort: true})
+
+	var done []Done
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		switch port {
+		case OutPort:
+			if data == "fail" {
+				return fmt.Errorf("failed")
+			}
+		case DonePort:
+			done = append(done, data.(Done))
+		}
+		return nil
+	}
+
+	_ = c.Handle(context.Background(), handler, module.ControlPort, Control{Context: "ok", Send: true})
+	_ = c.Handle(context.Background(), handler, module.ControlPort, Control{Context: "fail", Send: true})
+
+	if len(done) != 2 {
+		t.Fatalf("expected 2 done messages, got %d", len(done))
+	}
+	if done[0].Context != "ok" || done[0].Error != "" || done[0].SentAt.IsZero() {
+		t.Errorf("unexpected done message: %+v", done[0])
+	}
+	if done[1].Context != "fail" || done[1].Error != "failed" {
+		t.Errorf("unexpected done message: %+v", done[1])
+	}
+}
+
+func TestComponent_Sequence(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	var (
+		lock sync.Mutex
+		sent []string
+	)
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == module.ReconcilePort {
+			return nil
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		sent = append(sent, fmt.Sprintf("%s:%v", port, data))
+		if data == "fail" {
+			return fmt.Errorf("failed")
+		}
+		return nil
+	}
+
+	err := c.Handle(context.Background(), handler, module.SettingsPort, Settings{
+		Auto:              true,
+		EnableSignalPorts: true,
+		Signals: []SignalStep{
+			{Name: "kv", Context: "configure"},
+			{Name: "cache", Context: "prime", DelayMs: 100},
+			{Name: "cron", Context: "start"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	control, ok := c.getControl().(SendingControl)
+	if !ok || control.Status != "Step 2/3: cache" {
+		t.Errorf("unexpected control: %+v", c.getControl())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	lock.Lock()
+	if want := []string{"out_kv:configure", "out_cache:prime", "out_cron:start"}; !slices.Equal(sent, want) {
+		t.Errorf("expected %v, got %v", want, sent)
+	}
+	sent = nil
+	lock.Unlock()
+
+	// sequence is aborted on error
+	_ = c.Handle(context.Background(), handler, module.SettingsPort, Settings{
+		Signals: []SignalStep{{Name: "a", Context: "fail"}, {Name: "b", Context: "ok"}},
+	})
+	_ = c.Handle(context.Background(), handler, module.ControlPort, Control{Send: true})
+	time.Sleep(50 * time.Millisecond)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if want := []string{"out:fail"}; !slices.Equal(sent, want) {
+		t.Errorf("expected %v, got %v", want, sent)
+	}
+}
+
+func TestComponent_AutoDelay(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	var sent atomic.Int32
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			sent.Add(1)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	err := c.Handle(context.Background(), handler, module.SettingsPort, Settings{
+		Context:     "ping",
+		Auto:        true,
+		AutoDelayMs: 1500,
+	})
+	if err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Error("settings delivery was blocked by auto delay")
+	}
+
+	control, ok := c.getControl().(SendingControl)
+	if !ok || control.Status != "Sending in 2s" {
+		t.Errorf("unexpected control: %+v", c.getControl())
+	}
+	if sent.Load() != 0 {
+		t.Error("message was sent before auto delay")
+	}
+
+	time.Sleep(1600 * time.Millisecond)
+	if n := sent.Load(); n != 1 {
+		t.Errorf("expected message after auto delay, got %d", n)
+	}
+	if _, ok := c.getControl().(Control); !ok {
+		t.Errorf("expected send button after sending, got %T", c.getControl())
+	}
+
+	// stop cancels pending send
+	_ = c.Handle(context.Background(), handler, module.SettingsPort, Settings{Auto: true, AutoDelayMs: 50})
+	_ = c.Handle(context.Background(), handler, module.ControlPort, SendingControl{Stop: true})
+	time.Sleep(100 * time.Millisecond)
+	if n := sent.Load(); n != 1 {
+		t.Errorf("expected stopped auto start not to send, got %d", n)
+	}
+}
+
+func TestComponent_SendAt(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	var sent atomic.Int32
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			sent.Add(1)
+		}
+		return nil
+	}
+
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	if err := c.Handle(context.Background(), handler, module.SettingsPort, Settings{Context: "ping", SendAt: "tomorrow"}); err == nil {
+		t.Error("expected invalid send at to be rejected")
+	}
+	if err := c.Handle(context.Background(), handler, module.SettingsPort, Settings{Context: "ping", SendAt: past}); err == nil {
+		t.Error("expected past send at to be rejected by default")
+	}
+
+	settings := Settings{Context: "ping", SendAt: past, PastSendAt: PastSendAtSend}
+	if err := c.Handle(context.Background(), handler, module.SettingsPort, settings); err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := sent.Load(); n != 1 {
+		t.Fatalf("expected past send at to be sent immediately, got %d", n)
+	}
+
+	// already sent
+	if err := c.Handle(context.Background(), handler, module.SettingsPort, settings); err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := sent.Load(); n != 1 {
+		t.Errorf("expected send at to be sent once, got %d", n)
+	}
+
+	future := time.Now().Add(time.Hour)
+	if err := c.Handle(context.Background(), handler, module.SettingsPort, Settings{Context: "ping", SendAt: future.Format(time.RFC3339)}); err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+	control, ok := c.getControl().(SendingControl)
+	if !ok || control.Status != "Sending at "+future.Format(time.RFC3339) {
+		t.Errorf("unexpected control: %+v", c.getControl())
+	}
+
+	// control schedule replaces pending one
+	soon := time.Now().Add(time.Second).Format(time.RFC3339)
+	if err := c.Handle(context.Background(), handler, module.ControlPort, Control{Context: "pong", SendAt: soon}); err != nil {
+		t.Fatalf("control error: %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if n := sent.Load(); n != 2 {
+		t.Errorf("expected scheduled control send, got %d", n)
+	}
+	if _, ok := c.getControl().(Control); !ok {
+		t.Errorf("expected send button after sending, got %T", c.getControl())
+	}
+}
+
+func TestComponent_SentCount(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	fail := false
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort && fail {
+			return errors.New("receiver failed")
+		}
+		return nil
+	}
+
+	if control := c.getControl().(Control); control.SentCount != 0 || control.LastSentAt != "" {
+		t.Errorf("expected nothing sent yet, got %+v", control)
+	}
+
+	_ = c.Handle(context.Background(), handler, module.SettingsPort, Settings{Context: "ping"})
+	_ = c.Handle(context.Background(), handler, module.ControlPort, Control{Context: "ping", Send: true})
+	_ = c.Handle(context.Background(), handler, TriggerPort, TriggerMessage{})
+
+	fail = true
+	if err := c.Handle(context.Background(), handler, TriggerPort, TriggerMessage{}); err == nil {
+		t.Error("expected receiver error")
+	}
+
+	control := c.getControl().(Control)
+	if control.SentCount != 2 {
+		t.Errorf("expected failed send not to be counted, got %d", control.SentCount)
+	}
+	if control.TriggerCount != 2 {
+		t.Errorf("expected trigger count 2, got %d", control.TriggerCount)
+	}
+	lastSentAt, err := time.Parse(time.RFC3339, control.LastSentAt)
+	if err != nil {
+		t.Fatalf("invalid last sent at: %v", err)
+	}
+	if time.Since(lastSentAt) > time.Minute {
+		t.Errorf("unexpected last sent at: %s", control.LastSentAt)
+	}
+}
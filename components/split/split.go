@@ -1,37 +1,183 @@
 package split
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/spyzhov/ajson"
 	"github.com/tiny-systems/module/module"
 	"github.com/tiny-systems/module/registry"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
 	ComponentName        = "split"
 	OutPort       string = "out"
 	InPort        string = "in"
+	DonePort      string = "done"
+	ErrorPort     string = "error"
+	CollectPort   string = "collect"
+	CollectedPort string = "collected"
+	StopEventPort string = "stop_event"
+)
+
+const (
+	OnItemErrorAbort    = "abort"
+	OnItemErrorContinue = "continue"
+)
+
+const (
+	OnEmptySilent   = "silent"
+	OnEmptyError    = "error"
+	OnEmptyEmitDone = "emitDone"
+)
+
+const (
+	CollectTimeoutEmit    = "emit"
+	CollectTimeoutDiscard = "discard"
+)
+
+const (
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
 )
 
 type Context any
 
 type ItemContext any
 
+type Settings struct {
+	ChunkSize           int    `json:"chunkSize" title:"Chunk size" description:"Send items in chunks of this size. 1 or less sends each item separately" minimum:"0" default:"0"`
+	EnableDonePort      bool   `json:"enableDonePort" title:"Enable done port" description:"Done port receives a message after all items were sent, also for empty arrays"`
+	OnItemError         string `json:"onItemError" enum:"abort,continue" enumTitles:"Abort,Continue" default:"abort" title:"On item error" description:"Abort stops on first item not handled successfully. Continue sends remaining items and returns all errors at the end"`
+	EnableErrorPort     bool   `json:"enableErrorPort" title:"Enable error port" description:"Item errors in continue mode and empty array error are sent to error port instead of being returned"`
+	ItemDelayMs         int    `json:"itemDelayMs" title:"Item delay (ms)" description:"Delay between sending items. Zero sends items without delay" minimum:"0" default:"0"`
+	Filter              string `json:"filter" title:"Filter" description:"JSONPath predicate evaluated for each item, only matching items are sent. Example: $.price > 10"`
+	SortByPath          string `json:"sortByPath" title:"Sort by path" description:"JSONPath of the value items are sorted by. Numbers go before strings in ascending order and after them in descending one, items without number or string value always go last. Example: $.price"`
+	SortOrder           string `json:"sortOrder" enum:"asc,desc" enumTitles:"Ascending,Descending" default:"asc" title:"Sort order" description:"Items with equal values keep their original order in both orders"`
+	Reverse             bool   `json:"reverse" title:"Reverse" description:"Send items in reverse order, applied after sorting"`
+	Flatten             bool   `json:"flatten" title:"Flatten" description:"Expand nested arrays and send their elements as separate items"`
+	MaxDepth            int    `json:"maxDepth" title:"Max depth" description:"Maximum nesting depth to flatten. Zero means no limit" minimum:"0" default:"0"`
+	OnEmpty             string `json:"onEmpty" enum:"silent,error,emitDone" enumTitles:"Silent,Error,Emit done" title:"On empty" description:"What to do with empty array. Silent sends nothing, error returns error or sends it to error port if enabled, emit done sends done message if done port is enabled. Done message is sent by default"`
+	EnableStopEventPort bool   `json:"enableStopEventPort" title:"Enable stop event port" description:"In abort mode stop event port receives a message when sending was stopped by the item error"`
+	EnableCollect       bool   `json:"enableCollect" title:"Enable collect" description:"Results of sent items can be sent back to collect port, collected port receives all of them once every result arrived. In continue mode failed items are not waited for"`
+	CollectTimeoutMs    int    `json:"collectTimeoutMs" title:"Collect timeout (ms)" description:"Time to wait for results of a batch. Zero means waiting until every result arrived" minimum:"0" default:"0"`
+	OnCollectTimeout    string `json:"onCollectTimeout" enum:"emit,discard" enumTitles:"Emit,Discard" default:"emit" title:"On collect timeout" description:"Emit sends results received so far to collected port, discard drops the batch"`
+	Concurrency         int    `json:"concurrency" title:"Concurrency" description:"Number of items handled at the same time. Items may be handled out of order when greater than 1, index still reflects position in the array" minimum:"1" default:"1"`
+}
+
 type InMessage struct {
-	Context Context       `json:"context" title:"Context" configurable:"true"  description:"Message to be send further with each item"  configurable:"true"`
-	Array   []ItemContext `json:"array" title:"Array" default:"null" description:"Array of items to be split" required:"true"`
+	Context   Context                `json:"context" title:"Context" configurable:"true"  description:"Message to be send further with each item"  configurable:"true"`
+	Array     []ItemContext          `json:"array" title:"Array" default:"null" description:"Array of items to be split"`
+	Object    map[string]ItemContext `json:"object,omitempty" title:"Object" description:"Object to be split into key value entries sorted by key. Can not be used together with array"`
+	Text      string                 `json:"text,omitempty" title:"Text" description:"Text to be split by delimiter. Can not be used together with array or object"`
+	Delimiter string                 `json:"delimiter,omitempty" title:"Delimiter" description:"Delimiter of the text pieces" default:","`
+	TrimSpace bool                   `json:"trimSpace,omitempty" title:"Trim spaces" description:"Remove leading and trailing spaces of each text piece"`
+	SkipEmpty bool                   `json:"skipEmpty,omitempty" title:"Skip empty" description:"Do not send empty text pieces"`
+	Offset    int                    `json:"offset,omitempty" title:"Offset" description:"Number of items to skip" minimum:"0" default:"0"`
+	Limit     int                    `json:"limit,omitempty" title:"Limit" description:"Maximum number of items to send. Zero means no limit" minimum:"0" default:"0"`
+	Zip       []ItemContext          `json:"zip,omitempty" title:"Zip" description:"Array of the same length as the split one. Element at the same position is sent together with each item"`
 }
 
 type OutMessage struct {
 	Context Context     `json:"context"`
 	Item    ItemContext `json:"item"`
+	Zipped  ItemContext `json:"zipped,omitempty" title:"Zipped" description:"Element of the zip array at the same position as the item"`
+	Index   int         `json:"index" title:"Index" description:"Position of the item, starting from 1"`
+	Total   int         `json:"total" title:"Total" description:"Number of items to be sent"`
+	Last    bool        `json:"last" title:"Last" description:"True for the last item"`
+
+	BatchID    string `json:"batchID,omitempty" title:"Batch ID" description:"ID shared by all items of the same array, used to collect results back"`
+	TotalItems int    `json:"totalItems,omitempty" title:"Total items" description:"Number of results expected by collect port"`
+}
+
+// Entry is an item of the split object
+type Entry struct {
+	Key   string      `json:"key"`
+	Value ItemContext `json:"value"`
+}
+
+type ChunkMessage struct {
+	Context Context       `json:"context"`
+	Items   []ItemContext `json:"items"`
+	Zipped  []ItemContext `json:"zipped,omitempty" title:"Zipped" description:"Elements of the zip array at the same positions as the items"`
+	Index   int           `json:"index" title:"Index" description:"Position of the chunk, starting from 1"`
+	Total   int           `json:"total" title:"Total" description:"Number of chunks"`
+	Last    bool          `json:"last" title:"Last" description:"True for the last chunk"`
+
+	BatchID    string `json:"batchID,omitempty" title:"Batch ID" description:"ID shared by all chunks of the same array, used to collect results back"`
+	TotalItems int    `json:"totalItems,omitempty" title:"Total items" description:"Number of results expected by collect port"`
+}
+
+// StopEvent tells where sending was stopped in abort mode
+type StopEvent struct {
+	Context        Context `json:"context"`
+	StoppedAtIndex int     `json:"stoppedAtIndex" title:"Stopped at index" description:"Index of the failed item or chunk"`
+	Error          string  `json:"error"`
+	RemainingItems int     `json:"remainingItems" title:"Remaining items" description:"Number of items which were not sent"`
+}
+
+// CollectItem is a result of a sent item or chunk
+type CollectItem struct {
+	BatchID string `json:"batchID" required:"true" title:"Batch ID"`
+	Index   int    `json:"index" required:"true" title:"Index" description:"Index of the sent item or chunk"`
+	Result  any    `json:"result" configurable:"true" title:"Result"`
+}
+
+type CollectedResult struct {
+	BatchID         string  `json:"batchID"`
+	Items           []any   `json:"items" description:"Results ordered by index"`
+	OriginalContext Context `json:"originalContext"`
+	Missing         []int   `json:"missing,omitempty" title:"Missing" description:"Indexes of items which failed or did not send result back before collect timeout, their results are empty"`
+}
+
+type Done struct {
+	Context Context `json:"context"`
+	Total   int     `json:"total" title:"Total" description:"Number of items in the array"`
+	Matched int     `json:"matched" title:"Matched" description:"Number of items matched the filter"`
+	Skipped int     `json:"skipped" title:"Skipped" description:"Number of items skipped by the filter"`
+	Emitted int     `json:"emitted" title:"Emitted" description:"Number of items sent after offset and limit applied"`
+	Failed  int     `json:"failed" title:"Failed" description:"Number of items failed to be handled"`
+}
+
+type ItemError struct {
+	Context Context     `json:"context"`
+	Item    ItemContext `json:"item"`
+	Index   int         `json:"index" title:"Index" description:"Position of the item, starting from 1"`
+	Error   string      `json:"error"`
+}
+
+// batch waits for results of sent items
+type batch struct {
+	context  Context
+	items    []any
+	received []bool
+	count    int
+	// missing are indexes of items which will not send result back
+	missing []int
+	timer   *time.Timer
 }
 
 type Component struct {
+	settings Settings
+
+	batches     map[string]*batch
+	batchesLock *sync.Mutex
 }
 
 func (t *Component) Instance() module.Component {
-	return &Component{}
+	return &Component{
+		batches:     make(map[string]*batch),
+		batchesLock: &sync.Mutex{},
+	}
 }
 
 func (t *Component) GetInfo() module.ComponentInfo {
@@ -44,22 +190,665 @@ func (t *Component) GetInfo() module.ComponentInfo {
 }
 
 func (t *Component) Handle(ctx context.Context, handler module.Handler, port string, msg interface{}) error {
-	if in, ok := msg.(InMessage); ok {
-		for _, item := range in.Array {
-			if err := handler(ctx, OutPort, OutMessage{
-				Context: in.Context,
-				Item:    item,
-			}); err != nil {
+	if port == module.SettingsPort {
+		in, ok := msg.(Settings)
+		if !ok {
+			return fmt.Errorf("invalid settings")
+		}
+		switch in.OnItemError {
+		case "", OnItemErrorAbort, OnItemErrorContinue:
+		default:
+			return fmt.Errorf("unknown on item error mode: %s", in.OnItemError)
+		}
+		switch in.SortOrder {
+		case "", SortOrderAsc, SortOrderDesc:
+		default:
+			return fmt.Errorf("unknown sort order: %s", in.SortOrder)
+		}
+		switch in.OnEmpty {
+		case "", OnEmptySilent, OnEmptyError, OnEmptyEmitDone:
+		default:
+			return fmt.Errorf("unknown on empty mode: %s", in.OnEmpty)
+		}
+		switch in.OnCollectTimeout {
+		case "", CollectTimeoutEmit, CollectTimeoutDiscard:
+		default:
+			return fmt.Errorf("unknown on collect timeout mode: %s", in.OnCollectTimeout)
+		}
+		if in.SortByPath != "" {
+			if _, err := ajson.ParseJSONPath(in.SortByPath); err != nil {
+				return fmt.Errorf("invalid sort path: %v", err)
+			}
+		}
+		t.settings = in
+		return nil
+	}
+
+	if port == CollectPort {
+		in, ok := msg.(CollectItem)
+		if !ok {
+			return fmt.Errorf("invalid collect message")
+		}
+		result, err := t.collect(in)
+		if err != nil || result == nil {
+			return err
+		}
+		return handler(ctx, CollectedPort, *result)
+	}
+
+	in, ok := msg.(InMessage)
+	if !ok {
+		return fmt.Errorf("invalid message")
+	}
+	if in.Object != nil {
+		if in.Array != nil {
+			return fmt.Errorf("array and object can not be split together")
+		}
+		in.Array = entries(in.Object)
+	}
+	if in.Text != "" {
+		if in.Array != nil {
+			return fmt.Errorf("text can not be split together with array or object")
+		}
+		in.Array = pieces(in)
+	}
+	if t.settings.Flatten {
+		in.Array = flatten(in.Array, t.settings.MaxDepth)
+	}
+	if in.Zip != nil {
+		if len(in.Zip) != len(in.Array) {
+			return fmt.Errorf("zip length %d does not match array length %d", len(in.Zip), len(in.Array))
+		}
+		in.Array = zip(in.Array, in.Zip)
+	}
+	if len(in.Array) == 0 {
+		switch t.settings.OnEmpty {
+		case OnEmptySilent:
+			return nil
+		case OnEmptyError:
+			err := fmt.Errorf("array is empty")
+			if !t.settings.EnableErrorPort {
 				return err
 			}
+			return handler(ctx, ErrorPort, ItemError{
+				Context: in.Context,
+				Error:   err.Error(),
+			})
+		}
+	}
+	done, err := t.split(ctx, handler, in)
+	if err != nil && t.settings.OnItemError != OnItemErrorContinue {
+		return err
+	}
+	if t.settings.EnableDonePort {
+		done.Context = in.Context
+		if doneErr := handler(ctx, DonePort, done); doneErr != nil {
+			return doneErr
+		}
+	}
+	return err
+}
+
+// split sends items one by one or in chunks, returns counters for the done message.
+// In continue mode returned error combines errors of all failed items.
+// With concurrency greater than 1 items are handled by a bounded pool of goroutines, split still waits for all of them.
+func (t *Component) split(ctx context.Context, handler module.Handler, in InMessage) (Done, error) {
+	var (
+		done = Done{Total: len(in.Array)}
+		errs []error
+		lock sync.Mutex
+	)
+
+	var (
+		stop       *StopEvent
+		dispatched int
+		// planned is number of items stop event counts remaining ones from
+		planned int
+		batchID string
+	)
+
+	fail := func(index int, item ItemContext, size int, err error) error {
+		if t.settings.OnItemError != OnItemErrorContinue {
+			lock.Lock()
+			if stop == nil {
+				stop = &StopEvent{
+					Context:        in.Context,
+					StoppedAtIndex: index,
+					Error:          err.Error(),
+				}
+			}
+			lock.Unlock()
+			return err
+		}
+		// failed item never sends its result back
+		if err := t.skip(ctx, handler, batchID, index); err != nil {
+			return err
+		}
+		lock.Lock()
+		done.Failed += size
+		if !t.settings.EnableErrorPort {
+			errs = append(errs, fmt.Errorf("item %d: %w", index, err))
+			lock.Unlock()
+			return nil
+		}
+		lock.Unlock()
+		item, _ = unzip(item)
+		return handler(ctx, ErrorPort, ItemError{
+			Context: in.Context,
+			Item:    item,
+			Index:   index,
+			Error:   err.Error(),
+		})
+	}
+
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, max(t.settings.Concurrency, 1))
+		abortErr error
+	)
+
+	aborted := func() error {
+		lock.Lock()
+		defer lock.Unlock()
+		return abortErr
+	}
+
+	send := func(msg interface{}, index int, item ItemContext, size int) error {
+		if delay := t.settings.ItemDelayMs; delay > 0 && index > 1 {
+			timer := time.NewTimer(time.Duration(delay) * time.Millisecond)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+
+		lock.Lock()
+		dispatched += size
+		lock.Unlock()
+
+		if t.settings.Concurrency <= 1 {
+			if err := handler(ctx, OutPort, msg); err != nil {
+				return fail(index, item, size, err)
+			}
+			return nil
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := aborted(); err != nil {
+			<-sem
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := handler(ctx, OutPort, msg); err != nil {
+				if err = fail(index, item, size, err); err != nil {
+					lock.Lock()
+					abortErr = cmp.Or(abortErr, err)
+					lock.Unlock()
+				}
+			}
+		}()
+		return nil
+	}
+
+	// finish waits for items still being handled
+	finish := func(err error) error {
+		wg.Wait()
+		if err == nil {
+			err = abortErr
+		}
+		if err == nil {
+			err = errors.Join(errs...)
+		}
+		if err != nil && batchID != "" && t.settings.OnItemError != OnItemErrorContinue {
+			// aborted batch will never be complete
+			t.batchesLock.Lock()
+			t.drop(batchID)
+			t.batchesLock.Unlock()
+		}
+		if stop != nil && t.settings.EnableStopEventPort {
+			stop.RemainingItems = planned - dispatched
+			err = errors.Join(err, handler(ctx, StopEventPort, *stop))
+		}
+		return err
+	}
+
+	array := in.Array
+	if t.settings.Filter != "" {
+		array = make([]ItemContext, 0, len(in.Array))
+		for i, item := range in.Array {
+			ok, err := match(item, t.settings.Filter)
+			if err != nil {
+				if err = fail(i+1, item, 1, err); err != nil {
+					// nothing was sent, items after the failed one were not evaluated
+					planned = len(in.Array) - i - 1
+					err = finish(err)
+					return done, err
+				}
+				continue
+			}
+			if !ok {
+				done.Skipped++
+				continue
+			}
+			array = append(array, item)
+		}
+	}
+	done.Matched = len(array)
+	array = t.order(array)
+
+	if in.Offset > 0 {
+		array = array[min(in.Offset, len(array)):]
+	}
+	if in.Limit > 0 && len(array) > in.Limit {
+		array = array[:in.Limit]
+	}
+	done.Emitted = len(array)
+	planned = done.Emitted
+
+	// open registers batch of results to be collected
+	open := func(total int) {
+		if !t.settings.EnableCollect || total == 0 {
+			return
+		}
+		batchID = uuid.NewString()
+		b := &batch{
+			context:  in.Context,
+			items:    make([]any, total),
+			received: make([]bool, total),
+		}
+
+		t.batchesLock.Lock()
+		defer t.batchesLock.Unlock()
+		t.batches[batchID] = b
+		if timeout := t.settings.CollectTimeoutMs; timeout > 0 {
+			id, discard := batchID, t.settings.OnCollectTimeout == CollectTimeoutDiscard
+			b.timer = time.AfterFunc(time.Duration(timeout)*time.Millisecond, func() {
+				if result := t.expire(id, discard); result != nil {
+					_ = handler(context.WithoutCancel(ctx), CollectedPort, *result)
+				}
+			})
+		}
+	}
+
+	if size := t.settings.ChunkSize; size > 1 {
+		total := (len(array) + size - 1) / size
+		open(total)
+		for i := 0; i < total; i++ {
+			items, zipped := unzipAll(array[i*size : min((i+1)*size, len(array))])
+			if err := send(ChunkMessage{
+				Context: in.Context,
+				Items:   items,
+				Zipped:  zipped,
+				Index:   i + 1,
+				Total:   total,
+				Last:    i == total-1,
+
+				BatchID:    batchID,
+				TotalItems: batchTotal(batchID, total),
+			}, i+1, items, len(items)); err != nil {
+				err = finish(err)
+				return done, err
+			}
+		}
+		err := finish(nil)
+		return done, err
+	}
+
+	total := len(array)
+	open(total)
+	for i, item := range array {
+		item, zipped := unzip(item)
+		if err := send(OutMessage{
+			Context: in.Context,
+			Item:    item,
+			Zipped:  zipped,
+			Index:   i + 1,
+			Total:   total,
+			Last:    i == total-1,
+
+			BatchID:    batchID,
+			TotalItems: batchTotal(batchID, total),
+		}, i+1, item, 1); err != nil {
+			err = finish(err)
+			return done, err
+		}
+	}
+	err := finish(nil)
+	return done, err
+}
+
+// batchTotal returns number of results to collect, zero if results are not collected
+func batchTotal(batchID string, total int) int {
+	if batchID == "" {
+		return 0
+	}
+	return total
+}
+
+// collect stores item result, returns collected result once all results of the batch arrived
+func (t *Component) collect(in CollectItem) (*CollectedResult, error) {
+	t.batchesLock.Lock()
+	defer t.batchesLock.Unlock()
+
+	b, ok := t.batches[in.BatchID]
+	if !ok {
+		return nil, fmt.Errorf("unknown batch: %s", in.BatchID)
+	}
+	if in.Index < 1 || in.Index > len(b.items) {
+		return nil, fmt.Errorf("invalid index %d, batch has %d items", in.Index, len(b.items))
+	}
+
+	i := in.Index - 1
+	b.items[i] = in.Result
+	if !b.received[i] {
+		b.received[i] = true
+		b.count++
+	}
+	return t.complete(in.BatchID, b), nil
+}
+
+// skip stops waiting for result of the failed item, sends collected result if it was the last one awaited
+func (t *Component) skip(ctx context.Context, handler module.Handler, batchID string, index int) error {
+	if batchID == "" {
+		return nil
+	}
+	t.batchesLock.Lock()
+	b, ok := t.batches[batchID]
+	var result *CollectedResult
+	if ok && !b.received[index-1] {
+		b.received[index-1] = true
+		b.count++
+		b.missing = append(b.missing, index)
+		result = t.complete(batchID, b)
+	}
+	t.batchesLock.Unlock()
+
+	if result == nil {
+		return nil
+	}
+	return handler(ctx, CollectedPort, *result)
+}
+
+// expire drops batch which was not completed in time, returns results received so far unless discarded
+func (t *Component) expire(batchID string, discard bool) *CollectedResult {
+	t.batchesLock.Lock()
+	defer t.batchesLock.Unlock()
+
+	b, ok := t.batches[batchID]
+	if !ok {
+		return nil
+	}
+	t.drop(batchID)
+	if discard {
+		return nil
+	}
+	for i, received := range b.received {
+		if !received {
+			b.missing = append(b.missing, i+1)
 		}
+	}
+	return b.result(batchID)
+}
+
+// complete returns collected result and forgets the batch once every result is received, callers hold the lock
+func (t *Component) complete(batchID string, b *batch) *CollectedResult {
+	if b.count < len(b.items) {
 		return nil
 	}
-	return fmt.Errorf("invalid message")
+	t.drop(batchID)
+	return b.result(batchID)
+}
+
+// drop forgets the batch and stops its timeout, callers hold the lock
+func (t *Component) drop(batchID string) {
+	if b, ok := t.batches[batchID]; ok && b.timer != nil {
+		b.timer.Stop()
+	}
+	delete(t.batches, batchID)
+}
+
+func (b *batch) result(batchID string) *CollectedResult {
+	slices.Sort(b.missing)
+	return &CollectedResult{
+		BatchID:         batchID,
+		Items:           b.items,
+		OriginalContext: b.context,
+		Missing:         b.missing,
+	}
+}
+
+// entries converts object into key value entries sorted by key
+func entries(object map[string]ItemContext) []ItemContext {
+	keys := slices.Sorted(maps.Keys(object))
+	items := make([]ItemContext, len(keys))
+	for i, key := range keys {
+		items[i] = Entry{
+			Key:   key,
+			Value: object[key],
+		}
+	}
+	return items
+}
+
+// pieces splits text by delimiter, comma is used by default
+func pieces(in InMessage) []ItemContext {
+	delimiter := in.Delimiter
+	if delimiter == "" {
+		delimiter = ","
+	}
+
+	var items []ItemContext
+	for _, piece := range strings.Split(in.Text, delimiter) {
+		if in.TrimSpace {
+			piece = strings.TrimSpace(piece)
+		}
+		if in.SkipEmpty && piece == "" {
+			continue
+		}
+		items = append(items, piece)
+	}
+	return items
+}
+
+// flatten expands nested slices recursively up to the depth, zero depth means no limit
+func flatten(items []ItemContext, depth int) []ItemContext {
+	result := make([]ItemContext, 0, len(items))
+	for _, item := range items {
+		v := reflect.ValueOf(item)
+		if v.Kind() != reflect.Slice {
+			result = append(result, item)
+			continue
+		}
+		nested := make([]ItemContext, v.Len())
+		for i := range nested {
+			nested[i] = v.Index(i).Interface()
+		}
+		if depth != 1 {
+			nested = flatten(nested, max(depth-1, 0))
+		}
+		result = append(result, nested...)
+	}
+	return result
+}
+
+// zipped is an item sent together with the element of zip array
+type zipped struct {
+	item ItemContext
+	with ItemContext
+}
+
+// zip pairs items with elements of the same length array
+func zip(items []ItemContext, with []ItemContext) []ItemContext {
+	result := make([]ItemContext, len(items))
+	for i := range items {
+		result[i] = zipped{item: items[i], with: with[i]}
+	}
+	return result
+}
+
+// unzip returns original item and its zipped element if any
+func unzip(item ItemContext) (ItemContext, ItemContext) {
+	if z, ok := item.(zipped); ok {
+		return z.item, z.with
+	}
+	return item, nil
+}
+
+// unzipAll unzips chunk items, zipped elements are nil if items were not zipped
+func unzipAll(items []ItemContext) ([]ItemContext, []ItemContext) {
+	var (
+		result = make([]ItemContext, len(items))
+		with   []ItemContext
+	)
+	for i, item := range items {
+		if z, ok := item.(zipped); ok {
+			if with == nil {
+				with = make([]ItemContext, len(items))
+			}
+			result[i], with[i] = z.item, z.with
+			continue
+		}
+		result[i] = item
+	}
+	return result, with
+}
+
+// order returns sorted and reversed copy of items according to settings, items are never modified in place
+func (t *Component) order(items []ItemContext) []ItemContext {
+	if t.settings.SortByPath == "" && !t.settings.Reverse {
+		return items
+	}
+
+	type keyed struct {
+		item ItemContext
+		key  sortKey
+	}
+
+	ordered := make([]keyed, len(items))
+	for i, item := range items {
+		ordered[i] = keyed{item: item}
+		if t.settings.SortByPath != "" {
+			ordered[i].key = getSortKey(item, t.settings.SortByPath)
+		}
+	}
+
+	if t.settings.SortByPath != "" {
+		desc := t.settings.SortOrder == SortOrderDesc
+		slices.SortStableFunc(ordered, func(a, b keyed) int {
+			// items without value go last regardless of the order
+			if desc && (a.key.kind == sortKindOther) == (b.key.kind == sortKindOther) {
+				return b.key.compare(a.key)
+			}
+			return a.key.compare(b.key)
+		})
+	}
+	if t.settings.Reverse {
+		slices.Reverse(ordered)
+	}
+
+	result := make([]ItemContext, len(ordered))
+	for i, o := range ordered {
+		result[i] = o.item
+	}
+	return result
+}
+
+const (
+	sortKindNumber = iota
+	sortKindString
+	sortKindOther
+)
+
+type sortKey struct {
+	kind int
+	num  float64
+	str  string
+}
+
+func (k sortKey) compare(other sortKey) int {
+	if k.kind != other.kind {
+		return cmp.Compare(k.kind, other.kind)
+	}
+	switch k.kind {
+	case sortKindNumber:
+		return cmp.Compare(k.num, other.num)
+	case sortKindString:
+		return cmp.Compare(k.str, other.str)
+	}
+	return 0
+}
+
+// getSortKey finds value of the item by JSONPath, anything except number or string sorts last
+func getSortKey(item ItemContext, path string) sortKey {
+	key := sortKey{kind: sortKindOther}
+	item, _ = unzip(item)
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return key
+	}
+	nodes, err := ajson.JSONPath(data, path)
+	if err != nil || len(nodes) == 0 {
+		return key
+	}
+
+	switch node := nodes[0]; {
+	case node.IsNumeric():
+		if key.num, err = node.GetNumeric(); err == nil {
+			key.kind = sortKindNumber
+		}
+	case node.IsString():
+		if key.str, err = node.GetString(); err == nil {
+			key.kind = sortKindString
+		}
+	}
+	return key
+}
+
+// match evaluates JSONPath predicate against the item
+func match(item ItemContext, filter string) (bool, error) {
+	item, _ = unzip(item)
+	data, err := json.Marshal(item)
+	if err != nil {
+		return false, fmt.Errorf("unable to encode item: %v", err)
+	}
+	node, err := ajson.Unmarshal(data)
+	if err != nil {
+		return false, fmt.Errorf("unable to decode item: %v", err)
+	}
+	result, err := ajson.Eval(node, filter)
+	if err != nil {
+		return false, fmt.Errorf("unable to eval filter: %v", err)
+	}
+	v, err := result.Unpack()
+	if err != nil {
+		return false, fmt.Errorf("unable to get filter result: %v", err)
+	}
+	return v == true, nil
 }
 
 func (t *Component) Ports() []module.Port {
-	return []module.Port{
+	var out interface{} = OutMessage{}
+	if t.settings.ChunkSize > 1 {
+		out = ChunkMessage{}
+	}
+
+	ports := []module.Port{
+		{
+			Name:          module.SettingsPort,
+			Label:         "Settings",
+			Source:        true,
+			Configuration: t.settings,
+		},
 		{
 			Name:          InPort,
 			Label:         "In",
@@ -71,10 +860,57 @@ func (t *Component) Ports() []module.Port {
 			Name:          OutPort,
 			Label:         "Out",
 			Source:        false,
-			Configuration: OutMessage{},
+			Configuration: out,
 			Position:      module.Right,
 		},
 	}
+
+	if t.settings.EnableErrorPort {
+		ports = append(ports, module.Port{
+			Name:          ErrorPort,
+			Label:         "Error",
+			Source:        false,
+			Configuration: ItemError{},
+			Position:      module.Bottom,
+		})
+	}
+
+	if t.settings.EnableStopEventPort {
+		ports = append(ports, module.Port{
+			Name:          StopEventPort,
+			Label:         "Stop event",
+			Source:        false,
+			Configuration: StopEvent{},
+			Position:      module.Bottom,
+		})
+	}
+
+	if t.settings.EnableCollect {
+		ports = append(ports, module.Port{
+			Name:          CollectPort,
+			Label:         "Collect",
+			Source:        true,
+			Configuration: CollectItem{},
+			Position:      module.Left,
+		}, module.Port{
+			Name:          CollectedPort,
+			Label:         "Collected",
+			Source:        false,
+			Configuration: CollectedResult{},
+			Position:      module.Right,
+		})
+	}
+
+	if t.settings.EnableDonePort {
+		ports = append(ports, module.Port{
+			Name:          DonePort,
+			Label:         "Done",
+			Source:        false,
+			Configuration: Done{},
+			Position:      module.Bottom,
+		})
+	}
+	return ports
 }
 
 func init() {
@@ -2,13 +2,21 @@ package split
 
 import (
 	"context"
+	"fmt"
 	"github.com/tiny-systems/module/module"
 	"reflect"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 )
 
 type h func(ctx context.Context, handler module.Handler, port string, msg interface{}) error
 
+func noop(ctx context.Context, port string, data interface{}) error {
+	return nil
+}
+
 func TestSplit_Handle(t1 *testing.T) {
 	type args struct {
 		ctx     context.Context
@@ -97,3 +105,884 @@ func TestSplit_Handle(t1 *testing.T) {
 		})
 	}
 }
+
+func TestSplit_Chunks(t *testing.T) {
+	c := &Component{}
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{ChunkSize: 2})
+
+	var chunks [][]ItemContext
+	err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		chunks = append(chunks, data.(ChunkMessage).Items)
+		return nil
+	}, InPort, InMessage{Array: []ItemContext{1, 2, 3, 4, 5}})
+	if err != nil {
+		t.Fatalf("split error: %v", err)
+	}
+
+	want := [][]ItemContext{{1, 2}, {3, 4}, {5}}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("got chunks %v, want %v", chunks, want)
+	}
+}
+
+func TestSplit_Position(t *testing.T) {
+	c := &Component{}
+
+	var got []OutMessage
+	_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		got = append(got, data.(OutMessage))
+		return nil
+	}, InPort, InMessage{Array: []ItemContext{"a", "b", "c"}})
+
+	for i, msg := range got {
+		if msg.Index != i+1 || msg.Total != 3 || msg.Last != (i == 2) {
+			t.Errorf("unexpected position of item %d: %+v", i, msg)
+		}
+	}
+}
+
+func TestSplit_Done(t *testing.T) {
+	c := &Component{}
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableDonePort: true})
+
+	for _, array := range [][]ItemContext{{1, 2, 3}, {}} {
+		var (
+			ports []string
+			done  Done
+		)
+		err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+			ports = append(ports, port)
+			if port == DonePort {
+				done = data.(Done)
+			}
+			return nil
+		}, InPort, InMessage{Array: array, Context: "ctx"})
+		if err != nil {
+			t.Fatalf("split error: %v", err)
+		}
+		if len(ports) != len(array)+1 || ports[len(ports)-1] != DonePort {
+			t.Errorf("done should be sent after all items, got %v", ports)
+		}
+		if done.Total != len(array) || done.Context != "ctx" {
+			t.Errorf("unexpected done message: %+v", done)
+		}
+	}
+}
+
+func TestSplit_ContinueOnError(t *testing.T) {
+	// fails on 2nd and 4th items
+	failing := func(ctx context.Context, port string, data interface{}) error {
+		if msg, ok := data.(OutMessage); ok && msg.Index%2 == 0 {
+			return fmt.Errorf("item %v is bad", msg.Item)
+		}
+		return nil
+	}
+	array := []ItemContext{"a", "b", "c", "d", "e"}
+
+	t.Run("abort", func(t *testing.T) {
+		c := &Component{}
+		var sent int
+		err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+			sent++
+			return failing(ctx, port, data)
+		}, InPort, InMessage{Array: array})
+		if err == nil || sent != 2 {
+			t.Errorf("expected split to abort on second item, sent %d, err %v", sent, err)
+		}
+	})
+
+	t.Run("continue", func(t *testing.T) {
+		c := &Component{}
+		_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{OnItemError: OnItemErrorContinue, EnableDonePort: true})
+
+		var (
+			sent int
+			done Done
+		)
+		err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+			switch port {
+			case OutPort:
+				sent++
+			case DonePort:
+				done = data.(Done)
+			}
+			return failing(ctx, port, data)
+		}, InPort, InMessage{Array: array})
+		if sent != 5 {
+			t.Errorf("expected all items to be sent, got %d", sent)
+		}
+		if err == nil || err.Error() != "item 2: item b is bad\nitem 4: item d is bad" {
+			t.Errorf("unexpected combined error: %v", err)
+		}
+		if done.Failed != 2 || done.Total != 5 {
+			t.Errorf("unexpected done message: %+v", done)
+		}
+	})
+
+	t.Run("error port", func(t *testing.T) {
+		c := &Component{}
+		_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{OnItemError: OnItemErrorContinue, EnableErrorPort: true})
+
+		var errs []ItemError
+		err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+			if port == ErrorPort {
+				errs = append(errs, data.(ItemError))
+			}
+			return failing(ctx, port, data)
+		}, InPort, InMessage{Array: array, Context: "ctx"})
+		if err != nil {
+			t.Errorf("errors should be sent to error port, got %v", err)
+		}
+		if len(errs) != 2 || errs[0].Index != 2 || errs[0].Item != "b" || errs[1].Index != 4 || errs[1].Context != "ctx" {
+			t.Errorf("unexpected item errors: %+v", errs)
+		}
+	})
+}
+
+func TestSplit_ItemDelay(t *testing.T) {
+	c := &Component{}
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{ItemDelayMs: 20})
+
+	started := time.Now()
+	var sent int
+	err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		sent++
+		return nil
+	}, InPort, InMessage{Array: []ItemContext{1, 2, 3}})
+	if err != nil || sent != 3 {
+		t.Fatalf("unexpected result: sent %d, err %v", sent, err)
+	}
+	if elapsed := time.Since(started); elapsed < 40*time.Millisecond {
+		t.Errorf("items were sent without delay: %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	started = time.Now()
+	sent = 0
+	err = c.Handle(ctx, func(ctx context.Context, port string, data interface{}) error {
+		sent++
+		return nil
+	}, InPort, InMessage{Array: make([]ItemContext, 100)})
+	if err == nil || sent > 2 {
+		t.Errorf("cancelled split should stop, sent %d, err %v", sent, err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("cancelled split kept sleeping: %v", elapsed)
+	}
+}
+
+func TestSplit_Filter(t *testing.T) {
+	c := &Component{}
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Filter:          "$.price > 10",
+		EnableDonePort:  true,
+		OnItemError:     OnItemErrorContinue,
+		EnableErrorPort: true,
+	})
+
+	var (
+		items []OutMessage
+		errs  []ItemError
+		done  Done
+	)
+	err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		switch port {
+		case OutPort:
+			items = append(items, data.(OutMessage))
+		case ErrorPort:
+			errs = append(errs, data.(ItemError))
+		case DonePort:
+			done = data.(Done)
+		}
+		return nil
+	}, InPort, InMessage{Array: []ItemContext{
+		map[string]any{"price": 5},
+		map[string]any{"price": 15},
+		"not an object",
+		// can not be encoded
+		make(chan int),
+		map[string]any{"price": 25},
+	}})
+	if err != nil {
+		t.Fatalf("split error: %v", err)
+	}
+
+	if len(items) != 2 || items[1].Index != 2 || items[1].Total != 2 || !items[1].Last {
+		t.Errorf("unexpected items: %+v", items)
+	}
+	if done.Total != 5 || done.Matched != 2 || done.Skipped != 2 || done.Failed != 1 {
+		t.Errorf("unexpected done message: %+v", done)
+	}
+	if len(errs) != 1 || errs[0].Index != 4 {
+		t.Errorf("evaluation error should follow error policy: %+v", errs)
+	}
+}
+
+func TestSplit_Order(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		array    []ItemContext
+		want     []ItemContext
+	}{
+		{
+			name:     "reverse",
+			settings: Settings{Reverse: true},
+			array:    []ItemContext{1, 2, 3},
+			want:     []ItemContext{3, 2, 1},
+		},
+		{
+			name:     "numbers",
+			settings: Settings{SortByPath: "$.n"},
+			array:    []ItemContext{map[string]any{"n": 10}, map[string]any{"n": 2}, map[string]any{"n": 1.5}},
+			want:     []ItemContext{map[string]any{"n": 1.5}, map[string]any{"n": 2}, map[string]any{"n": 10}},
+		},
+		{
+			name:     "mixed types",
+			settings: Settings{SortByPath: "$.n"},
+			array: []ItemContext{
+				map[string]any{"n": "b"},
+				map[string]any{},
+				map[string]any{"n": 3},
+				map[string]any{"n": "a"},
+				map[string]any{"n": true},
+				map[string]any{"n": 1},
+			},
+			want: []ItemContext{
+				map[string]any{"n": 1},
+				map[string]any{"n": 3},
+				map[string]any{"n": "a"},
+				map[string]any{"n": "b"},
+				map[string]any{},
+				map[string]any{"n": true},
+			},
+		},
+		{
+			name:     "descending",
+			settings: Settings{SortByPath: "$.n", SortOrder: SortOrderDesc},
+			array:    []ItemContext{map[string]any{"n": "a"}, map[string]any{"n": 1}, map[string]any{"n": 2}},
+			want:     []ItemContext{map[string]any{"n": "a"}, map[string]any{"n": 2}, map[string]any{"n": 1}},
+		},
+		{
+			name:     "descending ties and values without type",
+			settings: Settings{SortByPath: "$.n", SortOrder: SortOrderDesc},
+			array: []ItemContext{
+				map[string]any{"id": 1},
+				map[string]any{"n": 1, "id": 2},
+				map[string]any{"n": 2},
+				map[string]any{"n": true},
+				map[string]any{"n": 1, "id": 3},
+			},
+			want: []ItemContext{
+				map[string]any{"n": 2},
+				map[string]any{"n": 1, "id": 2},
+				map[string]any{"n": 1, "id": 3},
+				map[string]any{"id": 1},
+				map[string]any{"n": true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Component{}
+			if err := c.Handle(context.Background(), nil, module.SettingsPort, tt.settings); err != nil {
+				t.Fatalf("settings error: %v", err)
+			}
+
+			original := slices.Clone(tt.array)
+			var got []ItemContext
+			_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+				got = append(got, data.(OutMessage).Item)
+				return nil
+			}, InPort, InMessage{Array: tt.array})
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.array, original) {
+				t.Errorf("input array was modified: %v", tt.array)
+			}
+		})
+	}
+}
+
+func TestSplit_LimitOffset(t *testing.T) {
+	c := &Component{}
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableDonePort: true})
+
+	var (
+		got  []ItemContext
+		done Done
+	)
+	err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		switch port {
+		case OutPort:
+			msg := data.(OutMessage)
+			if msg.Total != 2 {
+				t.Errorf("total should reflect number of items sent: %+v", msg)
+			}
+			got = append(got, msg.Item)
+		case DonePort:
+			done = data.(Done)
+		}
+		return nil
+	}, InPort, InMessage{Array: []ItemContext{1, 2, 3, 4, 5}, Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("split error: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, []ItemContext{2, 3}) {
+		t.Errorf("unexpected items: %v", got)
+	}
+	if done.Total != 5 || done.Emitted != 2 {
+		t.Errorf("unexpected done message: %+v", done)
+	}
+}
+
+func TestSplit_Object(t *testing.T) {
+	c := &Component{}
+
+	var got []OutMessage
+	err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		got = append(got, data.(OutMessage))
+		return nil
+	}, InPort, InMessage{Object: map[string]ItemContext{"b": 2, "c": 3, "a": 1}})
+	if err != nil {
+		t.Fatalf("split error: %v", err)
+	}
+
+	want := []Entry{{Key: "a", Value: 1}, {Key: "b", Value: 2}, {Key: "c", Value: 3}}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(got))
+	}
+	for i, msg := range got {
+		if msg.Item != want[i] || msg.Index != i+1 || msg.Total != 3 {
+			t.Errorf("unexpected entry: %+v", msg)
+		}
+	}
+
+	err = c.Handle(context.Background(), noop, InPort, InMessage{
+		Array:  []ItemContext{1},
+		Object: map[string]ItemContext{"a": 1},
+	})
+	if err == nil {
+		t.Error("expected error when both array and object provided")
+	}
+}
+
+func TestSplit_Text(t *testing.T) {
+	tests := []struct {
+		name string
+		in   InMessage
+		want []ItemContext
+	}{
+		{
+			name: "default delimiter",
+			in:   InMessage{Text: "a,b, c"},
+			want: []ItemContext{"a", "b", " c"},
+		},
+		{
+			name: "trim and skip empty",
+			in:   InMessage{Text: " a ; ;b;", Delimiter: ";", TrimSpace: true, SkipEmpty: true},
+			want: []ItemContext{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Component{}
+			_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableDonePort: true})
+
+			var (
+				got  []ItemContext
+				done Done
+			)
+			err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+				switch port {
+				case OutPort:
+					got = append(got, data.(OutMessage).Item)
+				case DonePort:
+					done = data.(Done)
+				}
+				return nil
+			}, InPort, tt.in)
+			if err != nil {
+				t.Fatalf("split error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if done.Total != len(tt.want) {
+				t.Errorf("unexpected done message: %+v", done)
+			}
+		})
+	}
+
+	c := &Component{}
+	if err := c.Handle(context.Background(), noop, InPort, InMessage{Text: "a,b", Array: []ItemContext{1}}); err == nil {
+		t.Error("expected error when both text and array provided")
+	}
+}
+
+func TestSplit_Flatten(t *testing.T) {
+	array := []ItemContext{
+		[]ItemContext{"a", []ItemContext{"b", []string{"c"}}},
+		"d",
+		[]ItemContext{},
+	}
+
+	tests := []struct {
+		name     string
+		maxDepth int
+		want     []ItemContext
+	}{
+		{name: "unlimited", want: []ItemContext{"a", "b", "c", "d"}},
+		{name: "depth 1", maxDepth: 1, want: []ItemContext{"a", []ItemContext{"b", []string{"c"}}, "d"}},
+		{name: "depth 2", maxDepth: 2, want: []ItemContext{"a", "b", []string{"c"}, "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Component{}
+			_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+				Flatten:        true,
+				MaxDepth:       tt.maxDepth,
+				EnableDonePort: true,
+			})
+
+			var (
+				got  []ItemContext
+				done Done
+			)
+			err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+				switch msg := data.(type) {
+				case OutMessage:
+					if msg.Total != len(tt.want) {
+						t.Errorf("expected total %d, got %d", len(tt.want), msg.Total)
+					}
+					got = append(got, msg.Item)
+				case Done:
+					done = msg
+				}
+				return nil
+			}, InPort, InMessage{Array: array})
+			if err != nil {
+				t.Fatalf("split error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+			if done.Total != len(tt.want) {
+				t.Errorf("expected done total %d, got %d", len(tt.want), done.Total)
+			}
+		})
+	}
+}
+
+func TestSplit_Concurrency(t *testing.T) {
+	c := &Component{}
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Concurrency:    4,
+		OnItemError:    OnItemErrorContinue,
+		EnableDonePort: true,
+	})
+
+	var (
+		lock     sync.Mutex
+		running  int
+		peak     int
+		indexes  []int
+		done     Done
+		array    = make([]ItemContext, 8)
+		started  = time.Now()
+		failItem = 3
+	)
+	err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		if port == DonePort {
+			done = data.(Done)
+			return nil
+		}
+		msg := data.(OutMessage)
+
+		lock.Lock()
+		running++
+		peak = max(peak, running)
+		indexes = append(indexes, msg.Index)
+		lock.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+
+		if msg.Index == failItem {
+			return fmt.Errorf("failed")
+		}
+		return nil
+	}, InPort, InMessage{Array: array})
+
+	if err == nil {
+		t.Error("expected error of the failed item")
+	}
+	if elapsed := time.Since(started); elapsed > 300*time.Millisecond {
+		t.Errorf("items were not handled concurrently, took %v", elapsed)
+	}
+	if peak > 4 {
+		t.Errorf("expected at most 4 items at the same time, got %d", peak)
+	}
+	slices.Sort(indexes)
+	if !slices.Equal(indexes, []int{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Errorf("unexpected indexes: %v", indexes)
+	}
+	if done.Emitted != 8 || done.Failed != 1 {
+		t.Errorf("unexpected done message: %+v", done)
+	}
+}
+
+func TestSplit_ConcurrencyAbort(t *testing.T) {
+	c := &Component{}
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{Concurrency: 2})
+
+	var (
+		lock  sync.Mutex
+		count int
+	)
+	err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		lock.Lock()
+		count++
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		return fmt.Errorf("failed")
+	}, InPort, InMessage{Array: make([]ItemContext, 10)})
+
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if count >= 10 {
+		t.Errorf("expected sending to stop after error, %d items were sent", count)
+	}
+}
+
+func TestSplit_OnEmpty(t *testing.T) {
+	tests := []struct {
+		name      string
+		onEmpty   string
+		donePort  bool
+		errorPort bool
+		wantPorts []string
+		wantErr   bool
+	}{
+		{name: "default", wantPorts: nil},
+		{name: "default with done port", donePort: true, wantPorts: []string{DonePort}},
+		{name: "silent", onEmpty: OnEmptySilent, wantPorts: nil},
+		{name: "silent with done port", onEmpty: OnEmptySilent, donePort: true, wantPorts: nil},
+		{name: "error", onEmpty: OnEmptyError, wantErr: true},
+		{name: "error with done port", onEmpty: OnEmptyError, donePort: true, wantErr: true},
+		{name: "error with error port", onEmpty: OnEmptyError, errorPort: true, wantPorts: []string{ErrorPort}},
+		{name: "emit done", onEmpty: OnEmptyEmitDone, wantPorts: nil},
+		{name: "emit done with done port", onEmpty: OnEmptyEmitDone, donePort: true, wantPorts: []string{DonePort}},
+		{name: "emit done with error port", onEmpty: OnEmptyEmitDone, errorPort: true, wantPorts: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Component{}
+			if err := c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+				OnEmpty:         tt.onEmpty,
+				EnableDonePort:  tt.donePort,
+				EnableErrorPort: tt.errorPort,
+			}); err != nil {
+				t.Fatalf("settings error: %v", err)
+			}
+
+			for _, array := range [][]ItemContext{nil, {}} {
+				var ports []string
+				err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+					ports = append(ports, port)
+					switch msg := data.(type) {
+					case Done:
+						if msg.Total != 0 || msg.Context != "ctx" {
+							t.Errorf("unexpected done message: %+v", msg)
+						}
+					case ItemError:
+						if msg.Error != "array is empty" || msg.Context != "ctx" {
+							t.Errorf("unexpected error message: %+v", msg)
+						}
+					}
+					return nil
+				}, InPort, InMessage{Array: array, Context: "ctx"})
+
+				if (err != nil) != tt.wantErr {
+					t.Errorf("expected error %v, got %v", tt.wantErr, err)
+				}
+				if !slices.Equal(ports, tt.wantPorts) {
+					t.Errorf("expected ports %v, got %v", tt.wantPorts, ports)
+				}
+			}
+		})
+	}
+
+	c := &Component{}
+	if err := c.Handle(context.Background(), nil, module.SettingsPort, Settings{OnEmpty: "ignore"}); err == nil {
+		t.Error("expected error for unknown on empty mode")
+	}
+}
+
+func TestSplit_Zip(t *testing.T) {
+	c := &Component{}
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{Reverse: true})
+
+	var got []OutMessage
+	err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		got = append(got, data.(OutMessage))
+		return nil
+	}, InPort, InMessage{
+		Array: []ItemContext{"a", "b", "c"},
+		Zip:   []ItemContext{1, 2, 3},
+	})
+	if err != nil {
+		t.Fatalf("split error: %v", err)
+	}
+	want := map[ItemContext]ItemContext{"a": 1, "b": 2, "c": 3}
+	if len(got) != 3 || got[0].Item != "c" {
+		t.Fatalf("unexpected messages: %+v", got)
+	}
+	for _, msg := range got {
+		if msg.Zipped != want[msg.Item] {
+			t.Errorf("item %v zipped with %v", msg.Item, msg.Zipped)
+		}
+	}
+
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{ChunkSize: 2})
+	var chunks []ChunkMessage
+	_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		chunks = append(chunks, data.(ChunkMessage))
+		return nil
+	}, InPort, InMessage{
+		Array: []ItemContext{"a", "b", "c"},
+		Zip:   []ItemContext{1, 2, 3},
+	})
+	if len(chunks) != 2 || !slices.Equal(chunks[0].Items, []ItemContext{"a", "b"}) || !slices.Equal(chunks[0].Zipped, []ItemContext{1, 2}) {
+		t.Errorf("unexpected chunks: %+v", chunks)
+	}
+
+	var sent int
+	err = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		sent++
+		return nil
+	}, InPort, InMessage{
+		Array: []ItemContext{"a", "b", "c"},
+		Zip:   []ItemContext{1, 2},
+	})
+	if err == nil {
+		t.Error("expected error for zip length mismatch")
+	}
+	if sent != 0 {
+		t.Errorf("expected nothing to be sent, %d messages were sent", sent)
+	}
+}
+
+func TestSplit_Collect(t *testing.T) {
+	c := (&Component{}).Instance()
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableCollect: true})
+
+	var (
+		sent      []OutMessage
+		collected []CollectedResult
+	)
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		switch port {
+		case OutPort:
+			sent = append(sent, data.(OutMessage))
+		case CollectedPort:
+			collected = append(collected, data.(CollectedResult))
+		}
+		return nil
+	}
+	err := c.Handle(context.Background(), handler, InPort, InMessage{
+		Context: "ctx",
+		Array:   []ItemContext{1, 2, 3},
+	})
+	if err != nil {
+		t.Fatalf("split error: %v", err)
+	}
+	if len(sent) != 3 || sent[0].BatchID == "" || sent[0].TotalItems != 3 {
+		t.Fatalf("unexpected messages: %+v", sent)
+	}
+
+	// results arrive out of order
+	for _, i := range []int{2, 0, 1} {
+		msg := sent[i]
+		if err = c.Handle(context.Background(), handler, CollectPort, CollectItem{
+			BatchID: msg.BatchID,
+			Index:   msg.Index,
+			Result:  msg.Item.(int) * 10,
+		}); err != nil {
+			t.Fatalf("collect error: %v", err)
+		}
+	}
+
+	if len(collected) != 1 {
+		t.Fatalf("expected 1 collected result, got %d", len(collected))
+	}
+	result := collected[0]
+	if result.BatchID != sent[0].BatchID || result.OriginalContext != "ctx" || !slices.Equal(result.Items, []any{10, 20, 30}) {
+		t.Errorf("unexpected collected result: %+v", result)
+	}
+
+	err = c.Handle(context.Background(), handler, CollectPort, CollectItem{BatchID: sent[0].BatchID, Index: 1})
+	if err == nil {
+		t.Error("expected error for completed batch")
+	}
+}
+
+func TestSplit_CollectFailed(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableCollect: true, OnItemError: OnItemErrorContinue, EnableErrorPort: true})
+
+	var collected []CollectedResult
+	var handler module.Handler
+	handler = func(ctx context.Context, port string, data interface{}) error {
+		switch port {
+		case OutPort:
+			msg := data.(OutMessage)
+			if msg.Index == 2 {
+				return fmt.Errorf("failed")
+			}
+			// results are sent back right away
+			return c.Handle(ctx, handler, CollectPort, CollectItem{BatchID: msg.BatchID, Index: msg.Index, Result: msg.Item})
+		case CollectedPort:
+			collected = append(collected, data.(CollectedResult))
+		}
+		return nil
+	}
+	if err := c.Handle(context.Background(), handler, InPort, InMessage{Array: []ItemContext{1, 2, 3}}); err != nil {
+		t.Fatalf("split error: %v", err)
+	}
+
+	if len(collected) != 1 {
+		t.Fatalf("expected 1 collected result, got %d", len(collected))
+	}
+	if !slices.Equal(collected[0].Items, []any{1, nil, 3}) || !slices.Equal(collected[0].Missing, []int{2}) {
+		t.Errorf("unexpected collected result: %+v", collected[0])
+	}
+	if len(c.batches) != 0 {
+		t.Errorf("expected completed batch to be forgotten, got %d batches", len(c.batches))
+	}
+}
+
+func TestSplit_CollectTimeout(t *testing.T) {
+	for _, mode := range []string{CollectTimeoutEmit, CollectTimeoutDiscard} {
+		t.Run(mode, func(t *testing.T) {
+			c := (&Component{}).Instance().(*Component)
+			_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableCollect: true, CollectTimeoutMs: 50, OnCollectTimeout: mode})
+
+			var (
+				lock      sync.Mutex
+				sent      []OutMessage
+				collected []CollectedResult
+			)
+			handler := func(ctx context.Context, port string, data interface{}) error {
+				lock.Lock()
+				defer lock.Unlock()
+				switch port {
+				case OutPort:
+					sent = append(sent, data.(OutMessage))
+				case CollectedPort:
+					collected = append(collected, data.(CollectedResult))
+				}
+				return nil
+			}
+			if err := c.Handle(context.Background(), handler, InPort, InMessage{Context: "ctx", Array: []ItemContext{1, 2, 3}}); err != nil {
+				t.Fatalf("split error: %v", err)
+			}
+			// second item result never arrives
+			for _, i := range []int{0, 2} {
+				_ = c.Handle(context.Background(), handler, CollectPort, CollectItem{BatchID: sent[i].BatchID, Index: sent[i].Index, Result: sent[i].Item})
+			}
+
+			time.Sleep(100 * time.Millisecond)
+
+			c.batchesLock.Lock()
+			batches := len(c.batches)
+			c.batchesLock.Unlock()
+			if batches != 0 {
+				t.Errorf("expected stale batch to be forgotten, got %d batches", batches)
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			if mode == CollectTimeoutDiscard {
+				if len(collected) != 0 {
+					t.Errorf("expected discarded batch, got %+v", collected)
+				}
+				return
+			}
+			if len(collected) != 1 {
+				t.Fatalf("expected 1 collected result, got %d", len(collected))
+			}
+			result := collected[0]
+			if result.OriginalContext != "ctx" || !slices.Equal(result.Items, []any{1, nil, 3}) || !slices.Equal(result.Missing, []int{2}) {
+				t.Errorf("unexpected collected result: %+v", result)
+			}
+		})
+	}
+}
+
+func TestSplit_StopEvent(t *testing.T) {
+	c := &Component{}
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableStopEventPort: true})
+
+	var (
+		sent  []int
+		stops []StopEvent
+	)
+	err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		switch msg := data.(type) {
+		case OutMessage:
+			sent = append(sent, msg.Index)
+			if msg.Index == 3 {
+				return fmt.Errorf("failed")
+			}
+		case StopEvent:
+			stops = append(stops, msg)
+		}
+		return nil
+	}, InPort, InMessage{Context: "ctx", Array: []ItemContext{1, 2, 3, 4, 5}})
+
+	if err == nil {
+		t.Error("expected error of the failed item")
+	}
+	if !slices.Equal(sent, []int{1, 2, 3}) {
+		t.Errorf("expected items 1-3 to be sent, got %v", sent)
+	}
+	want := StopEvent{Context: "ctx", StoppedAtIndex: 3, Error: "failed", RemainingItems: 2}
+	if len(stops) != 1 || stops[0] != want {
+		t.Errorf("unexpected stop events: %+v", stops)
+	}
+}
+
+func TestSplit_StopEventFilter(t *testing.T) {
+	c := &Component{}
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{EnableStopEventPort: true, Filter: "$.n > 1"})
+
+	var stops []StopEvent
+	err := c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		if msg, ok := data.(StopEvent); ok {
+			stops = append(stops, msg)
+		}
+		return nil
+	}, InPort, InMessage{Context: "ctx", Array: []ItemContext{
+		map[string]any{"n": 2},
+		// can not be encoded
+		make(chan int),
+		map[string]any{"n": 3},
+	}})
+
+	if err == nil {
+		t.Error("expected filter error")
+	}
+	if len(stops) != 1 || stops[0].StoppedAtIndex != 2 || stops[0].Context != "ctx" || stops[0].Error == "" || stops[0].RemainingItems != 1 {
+		t.Errorf("unexpected stop events: %+v", stops)
+	}
+}
@@ -3,6 +3,7 @@ package ticker
 import (
 	"context"
 	"fmt"
+	"github.com/tiny-systems/common-module/internal/clock"
 	"github.com/tiny-systems/module/module"
 	"github.com/tiny-systems/module/registry"
 	"go.opentelemetry.io/otel/trace"
@@ -13,30 +14,131 @@ import (
 const (
 	ComponentName        = "ticker"
 	OutPort       string = "out"
+	StartPort     string = "start"
+	StopPort      string = "stop"
+	ErrorPort     string = "error"
+	ResetPort     string = "reset"
+	FeedbackPort  string = "feedback"
+	PausePort     string = "pause"
+	ResumePort    string = "resume"
+)
+
+const (
+	OnErrorIgnore = "ignore"
+	OnErrorStop   = "stop"
+	OnErrorEmit   = "emit"
 )
 
 type Context any
 
 type Settings struct {
-	Context Context `json:"context,omitempty" configurable:"true" title:"Context" description:"Arbitrary message to be send each period of time"`
-	Delay   int     `json:"delay" required:"true" title:"Delay (ms)" description:"Delay between signals" minimum:"0" default:"1000"`
-	Auto    bool    `json:"auto" title:"Auto send" required:"true" description:"Start sending as soon as component configured"`
+	Context         Context  `json:"context,omitempty" configurable:"true" title:"Context" description:"Arbitrary message to be send each period of time"`
+	Delay           int      `json:"delay" required:"true" title:"Delay (ms)" description:"Delay between signals" minimum:"0" default:"1000"`
+	Interval        string   `json:"interval" title:"Interval" description:"Delay between signals as duration, e.g. 30s, 5m, 1h30m. Overrides delay if set"`
+	Auto            bool     `json:"auto" title:"Auto send" required:"true" description:"Start sending as soon as component configured"`
+	FireOnStart     bool     `json:"fireOnStart" title:"Fire on start" description:"Send first message as soon as ticker started instead of waiting for the delay"`
+	MaxCount        int      `json:"maxCount" title:"Max count" description:"Stop after sending this number of messages. Zero means unlimited" minimum:"0" default:"0"`
+	EmitTickInfo    bool     `json:"emitTickInfo" title:"Emit tick info" description:"Wrap context into a message with tick number and time it was fired"`
+	EnableStartPort bool     `json:"enableStartPort" title:"Enable start port" description:"Start port allows you to start ticker"`
+	EnableStopPort  bool     `json:"enableStopPort" title:"Enable stop port" description:"Stop port allows you to stop ticker"`
+	OnError         string   `json:"onError" enum:"ignore,stop,emit" enumTitles:"Ignore,Stop,Send to error port" default:"ignore" title:"On error" description:"What to do if message was not handled successfully"`
+	Backoff         Backoff  `json:"backoff" title:"Backoff" description:"Grow delay after each message"`
+	Adaptive        Adaptive `json:"adaptive" title:"Adaptive" description:"Adjust delay using latency reported by downstream"`
+}
+
+// interval returns base delay between ticks, Interval wins over Delay
+func (s Settings) interval() (time.Duration, error) {
+	if s.Interval == "" {
+		return time.Duration(s.Delay) * time.Millisecond, nil
+	}
+	d, err := time.ParseDuration(s.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval: %w", err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid interval: %s is negative", s.Interval)
+	}
+	return d, nil
+}
+
+type Backoff struct {
+	Factor          float64 `json:"factor" title:"Factor" description:"Delay is multiplied by the factor after each message. 1 or less keeps delay fixed" minimum:"0" default:"1"`
+	MaxDelayMs      int     `json:"maxDelayMs" title:"Max delay (ms)" description:"Delay does not grow above this value. Zero means no limit" minimum:"0" default:"0"`
+	EnableResetPort bool    `json:"enableResetPort" title:"Enable reset port" description:"Reset port sets delay back to its base value"`
+}
+
+type Adaptive struct {
+	Enabled         bool `json:"enabled" title:"Enabled" description:"Enables feedback port, delay grows by 10% when downstream is slow and shrinks by 10% when it is fast"`
+	MaxLatencyMs    int  `json:"maxLatencyMs" title:"Max latency (ms)" description:"Delay grows when reported latency is above this value" minimum:"0" default:"1000"`
+	TargetLatencyMs int  `json:"targetLatencyMs" title:"Target latency (ms)" description:"Delay shrinks when reported latency is below this value" minimum:"0" default:"100"`
+	MinDelayMs      int  `json:"minDelayMs" title:"Min delay (ms)" description:"Delay does not shrink below this value" minimum:"0" default:"0"`
+	MaxDelayMs      int  `json:"maxDelayMs" title:"Max delay (ms)" description:"Delay does not grow above this value. Zero means no limit" minimum:"0" default:"0"`
+}
+
+type Feedback struct {
+	Latency time.Duration `json:"latency" required:"true" title:"Latency" description:"Time downstream took to process a message, nanoseconds"`
+}
+
+type TickError struct {
+	Error   string  `json:"error"`
+	Context Context `json:"context"`
+	Tick    int     `json:"tick"`
+}
+
+type Start struct {
+}
+
+type Stop struct {
+}
+
+type Reset struct {
+}
+
+type Pause struct {
+}
+
+type Resume struct {
+}
+
+type TickInfo struct {
+	Context Context   `json:"context"`
+	Tick    int       `json:"tick"`
+	FiredAt time.Time `json:"firedAt"`
 }
 
 type Component struct {
-	settings Settings
-	ctx      context.Context
+	settings     Settings
+	settingsLock *sync.Mutex
+	// effective delay when backoff is on, zero means base delay from settings
+	delay time.Duration
+	// settingsChanged signals running loop to pick up new delay
+	settingsChanged chan struct{}
+	// resumed wakes up paused loop
+	resumed chan struct{}
+	ctx     context.Context
 
 	cancelFunc     context.CancelFunc
 	cancelFuncLock *sync.Mutex
+	// why the last run finished by itself, empty if it was stopped
+	finished string
+	// paused loop keeps running but sends nothing until resumed
+	paused   bool
+	count    int
+	lastTick time.Time
 
 	runLock *sync.Mutex
+
+	clock clock.Clock
 }
 
 func (t *Component) Instance() module.Component {
 	return &Component{
-		cancelFuncLock: &sync.Mutex{},
-		runLock:        &sync.Mutex{},
+		settingsLock:    &sync.Mutex{},
+		settingsChanged: make(chan struct{}, 1),
+		resumed:         make(chan struct{}, 1),
+		cancelFuncLock:  &sync.Mutex{},
+		runLock:         &sync.Mutex{},
+		clock:           clock.RealClock{},
 		settings: Settings{
 			Delay: 1000,
 		},
@@ -46,12 +148,26 @@ func (t *Component) Instance() module.Component {
 type StartControl struct {
 	Context Context `json:"context" required:"true" title:"Context"`
 	Status  string  `json:"status" title:"Status" readonly:"true"`
+	Paused  bool    `json:"paused" title:"Paused" readonly:"true"`
 	Start   bool    `json:"start" format:"button" title:"Start" required:"true"`
 }
 
 type StopControl struct {
+	Context  Context   `json:"context" required:"true" title:"Context"`
+	Status   string    `json:"status" title:"Status" readonly:"true"`
+	Delay    int       `json:"delay" title:"Current delay (ms)" readonly:"true"`
+	Ticks    int       `json:"ticks" title:"Ticks" readonly:"true"`
+	LastTick time.Time `json:"lastTick,omitempty" title:"Last tick" readonly:"true"`
+	Paused   bool      `json:"paused" title:"Paused" readonly:"true"`
+	Pause    bool      `json:"pause" format:"button" title:"Pause" required:"true"`
+	Stop     bool      `json:"stop" format:"button" title:"Stop" required:"true"`
+}
+
+type PausedControl struct {
 	Context Context `json:"context" required:"true" title:"Context"`
 	Status  string  `json:"status" title:"Status" readonly:"true"`
+	Paused  bool    `json:"paused" title:"Paused" readonly:"true"`
+	Resume  bool    `json:"resume" format:"button" title:"Resume" required:"true"`
 	Stop    bool    `json:"stop" format:"button" title:"Stop" required:"true"`
 }
 
@@ -64,16 +180,39 @@ func (t *Component) GetInfo() module.ComponentInfo {
 	}
 }
 
-// Emit non a pointer receiver copies Component with copy of settings
-func (t *Component) emit(ctx context.Context, handler module.Handler) error {
-
+// start stops the running loop if any, waits until it exits and prepares a new run context.
+// runLock stays locked until the new loop returns, so loops never stack.
+func (t *Component) start(ctx context.Context) (context.Context, context.CancelFunc) {
+	_ = t.stop()
 	t.runLock.Lock()
-	defer t.runLock.Unlock()
 
 	runCtx, runCancel := context.WithCancel(ctx)
+	t.setCancelFunc(runCancel)
+	t.setFinished("")
+	t.resetCount()
+	t.resetDelay()
+	return runCtx, runCancel
+}
+
+// emit blocks until ticker stopped
+func (t *Component) emit(ctx context.Context, handler module.Handler) error {
+	runCtx, runCancel := t.start(ctx)
+	return t.run(runCtx, runCancel, handler)
+}
+
+// emitAsync runs ticker in background, returns as soon as the loop is set up
+func (t *Component) emitAsync(ctx context.Context, handler module.Handler) {
+	runCtx, runCancel := t.start(ctx)
+	go func() {
+		_ = t.run(runCtx, runCancel, handler)
+	}()
+}
+
+// run sends ticks until runCtx is done, settings are read before each tick so updates apply without restart
+func (t *Component) run(runCtx context.Context, runCancel context.CancelFunc, handler module.Handler) error {
+	defer t.runLock.Unlock()
 	defer runCancel()
 
-	t.setCancelFunc(runCancel)
 	// reconcile so show we are listening
 	_ = handler(context.Background(), module.ReconcilePort, nil)
 
@@ -82,19 +221,99 @@ func (t *Component) emit(ctx context.Context, handler module.Handler) error {
 		_ = handler(context.Background(), module.ReconcilePort, nil)
 	}()
 
+	first := true
+	for {
+		if err := t.waitResumed(runCtx); err != nil {
+			return err
+		}
+		// first tick goes out right away if asked to
+		if !first || !t.getSettings().FireOnStart {
+			if err := t.wait(runCtx); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		if t.isPaused() {
+			// paused while waiting, tick is skipped
+			continue
+		}
+
+		settings := t.getSettings()
+
+		count := t.nextCount()
+
+		var data interface{} = settings.Context
+		if settings.EmitTickInfo {
+			data = TickInfo{
+				Context: settings.Context,
+				Tick:    count,
+				FiredAt: t.clock.Now(),
+			}
+		}
+		err := handler(trace.ContextWithSpanContext(runCtx, trace.NewSpanContext(trace.SpanContextConfig{})), OutPort, data)
+
+		if err != nil && runCtx.Err() == nil {
+			switch settings.OnError {
+			case OnErrorStop:
+				t.setFinished(fmt.Sprintf("Stopped on error: %v", err))
+				return nil
+			case OnErrorEmit:
+				_ = handler(runCtx, ErrorPort, TickError{
+					Error:   err.Error(),
+					Context: settings.Context,
+					Tick:    count,
+				})
+			}
+		}
+
+		if settings.MaxCount > 0 && count >= settings.MaxCount {
+			t.setFinished(fmt.Sprintf("Completed (%d ticks)", count))
+			return nil
+		}
+
+		t.rampDelay()
+		// show ticker is alive
+		_ = handler(context.Background(), module.ReconcilePort, nil)
+	}
+}
+
+// wait sleeps for the current delay, picking up delay changes while waiting
+func (t *Component) wait(runCtx context.Context) error {
+	waitFrom := t.clock.Now()
+	timer := t.clock.NewTimer(t.getDelay())
+	defer func() {
+		timer.Stop()
+	}()
+
 	for {
-		timer := time.NewTimer(time.Duration(t.settings.Delay) * time.Millisecond)
 		select {
-		case <-timer.C:
-			_ = handler(trace.ContextWithSpanContext(runCtx, trace.NewSpanContext(trace.SpanContextConfig{})), OutPort, t.settings.Context)
+		case <-t.settingsChanged:
+			// reschedule the tick in flight using the new delay
+			timer.Stop()
+			timer = t.clock.NewTimer(max(0, t.getDelay()-t.clock.Now().Sub(waitFrom)))
+
+		case <-timer.C():
+			return nil
 
 		case <-runCtx.Done():
-			timer.Stop()
 			return runCtx.Err()
 		}
 	}
 }
 
+// waitResumed blocks while ticker is paused
+func (t *Component) waitResumed(runCtx context.Context) error {
+	for t.isPaused() {
+		select {
+		case <-t.resumed:
+		case <-runCtx.Done():
+			return runCtx.Err()
+		}
+	}
+	return nil
+}
+
 func (t *Component) Handle(ctx context.Context, handler module.Handler, port string, msg interface{}) error {
 
 	switch port {
@@ -103,12 +322,19 @@ func (t *Component) Handle(ctx context.Context, handler module.Handler, port str
 		if !ok {
 			return fmt.Errorf("invalid settings")
 		}
-		t.settings = in
+		switch in.OnError {
+		case "", OnErrorIgnore, OnErrorStop, OnErrorEmit:
+		default:
+			return fmt.Errorf("unknown on error mode: %s", in.OnError)
+		}
+		if _, err := in.interval(); err != nil {
+			return err
+		}
+		t.setSettings(in)
 
-		if t.settings.Auto {
-			// stop if its already running
-			_ = t.stop()
-			return t.emit(ctx, handler)
+		if in.Auto && !t.isRunning() {
+			// running loop picks up new settings by itself
+			t.emitAsync(ctx, handler)
 		}
 
 		return nil
@@ -119,11 +345,54 @@ func (t *Component) Handle(ctx context.Context, handler module.Handler, port str
 		}
 		switch msg.(type) {
 		case StartControl:
-			t.settings.Context = msg.(StartControl).Context
+			settings := t.getSettings()
+			settings.Context = msg.(StartControl).Context
+			t.setSettings(settings)
 			return t.emit(ctx, handler)
 		case StopControl:
+			if msg.(StopControl).Pause {
+				t.setPaused(true)
+				return handler(context.Background(), module.ReconcilePort, nil)
+			}
 			return t.stop()
+		case PausedControl:
+			if msg.(PausedControl).Resume {
+				t.setPaused(false)
+				return handler(context.Background(), module.ReconcilePort, nil)
+			}
+			return t.stop()
+		}
+
+	case StartPort:
+		// do not block the sender, ticker keeps running after the message is handled
+		t.emitAsync(ctx, handler)
+		return nil
+
+	case StopPort:
+		return t.stop()
+
+	case PausePort:
+		t.setPaused(true)
+		return handler(context.Background(), module.ReconcilePort, nil)
+
+	case ResumePort:
+		t.setPaused(false)
+		return handler(context.Background(), module.ReconcilePort, nil)
+
+	case ResetPort:
+		t.resetDelay()
+		return handler(context.Background(), module.ReconcilePort, nil)
+
+	case FeedbackPort:
+		in, ok := msg.(Feedback)
+		if !ok {
+			return fmt.Errorf("invalid feedback")
+		}
+		if !t.adaptDelay(in.Latency) {
+			// feedback may come with every tick, reconcile only on change
+			return nil
 		}
+		return handler(context.Background(), module.ReconcilePort, nil)
 	}
 
 	return fmt.Errorf("invalid port: %s", port)
@@ -133,6 +402,174 @@ func (t *Component) setCancelFunc(f func()) {
 	t.cancelFuncLock.Lock()
 	defer t.cancelFuncLock.Unlock()
 	t.cancelFunc = f
+	if f == nil {
+		t.paused = false
+	}
+}
+
+func (t *Component) getSettings() Settings {
+	t.settingsLock.Lock()
+	defer t.settingsLock.Unlock()
+	return t.settings
+}
+
+func (t *Component) setSettings(settings Settings) {
+	t.settingsLock.Lock()
+	t.settings = settings
+	t.delay = 0
+	t.settingsLock.Unlock()
+
+	select {
+	case t.settingsChanged <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Component) getDelay() time.Duration {
+	t.settingsLock.Lock()
+	defer t.settingsLock.Unlock()
+	if t.delay > 0 {
+		return t.delay
+	}
+	d, _ := t.settings.interval()
+	return d
+}
+
+// rampDelay grows effective delay according to backoff settings
+func (t *Component) rampDelay() {
+	t.settingsLock.Lock()
+	defer t.settingsLock.Unlock()
+
+	backoff := t.settings.Backoff
+	if backoff.Factor <= 1 {
+		return
+	}
+
+	current := t.delay
+	if current == 0 {
+		current, _ = t.settings.interval()
+	}
+	next := time.Duration(float64(current) * backoff.Factor)
+	if maxDelay := time.Duration(backoff.MaxDelayMs) * time.Millisecond; maxDelay > 0 && next > maxDelay {
+		next = maxDelay
+	}
+	t.delay = next
+}
+
+// adaptDelay adjusts effective delay by 10% according to latency reported by downstream, reports if delay changed
+func (t *Component) adaptDelay(latency time.Duration) bool {
+	t.settingsLock.Lock()
+
+	adaptive := t.settings.Adaptive
+	if !adaptive.Enabled {
+		t.settingsLock.Unlock()
+		return false
+	}
+
+	current := t.delay
+	if current == 0 {
+		current, _ = t.settings.interval()
+	}
+	// tiny delay still changes
+	step := max(current/10, time.Millisecond)
+	next := current
+	switch {
+	case latency > time.Duration(adaptive.MaxLatencyMs)*time.Millisecond:
+		next = current + step
+		if maxDelay := time.Duration(adaptive.MaxDelayMs) * time.Millisecond; maxDelay > 0 && next > maxDelay {
+			next = maxDelay
+		}
+	case latency < time.Duration(adaptive.TargetLatencyMs)*time.Millisecond:
+		if current > step {
+			next = current - step
+		}
+		if minDelay := time.Duration(adaptive.MinDelayMs) * time.Millisecond; next < minDelay {
+			next = minDelay
+		}
+	}
+	t.delay = next
+	t.settingsLock.Unlock()
+
+	if next == current {
+		return false
+	}
+	select {
+	case t.settingsChanged <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// resetDelay sets effective delay back to its base value
+func (t *Component) resetDelay() {
+	t.settingsLock.Lock()
+	t.delay = 0
+	t.settingsLock.Unlock()
+
+	select {
+	case t.settingsChanged <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Component) setFinished(status string) {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	t.finished = status
+}
+
+func (t *Component) getFinished() string {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	return t.finished
+}
+
+// setPaused suspends or resumes running loop, ticker which is not running can not be paused
+func (t *Component) setPaused(paused bool) {
+	t.cancelFuncLock.Lock()
+	t.paused = paused && t.cancelFunc != nil
+	t.cancelFuncLock.Unlock()
+
+	if paused {
+		return
+	}
+	select {
+	case t.resumed <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Component) isPaused() bool {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	return t.paused
+}
+
+func (t *Component) nextCount() int {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	t.count++
+	t.lastTick = t.clock.Now()
+	return t.count
+}
+
+func (t *Component) resetCount() {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	t.count = 0
+	t.lastTick = time.Time{}
+}
+
+func (t *Component) getCount() int {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	return t.count
+}
+
+func (t *Component) getLastTick() time.Time {
+	t.cancelFuncLock.Lock()
+	defer t.cancelFuncLock.Unlock()
+	return t.lastTick
 }
 
 func (t *Component) isRunning() bool {
@@ -154,19 +591,26 @@ func (t *Component) stop() error {
 
 func (t *Component) Ports() []module.Port {
 
+	settings := t.getSettings()
+
+	var out interface{} = new(Context)
+	if settings.EmitTickInfo {
+		out = TickInfo{}
+	}
+
 	ports := []module.Port{
 		{
 			Name:          module.SettingsPort,
 			Label:         "Settings",
 			Source:        true,
-			Configuration: t.settings,
+			Configuration: settings,
 		},
 		{
 			Name:          OutPort,
 			Label:         "Out",
 			Source:        false,
 			Position:      module.Right,
-			Configuration: new(Context),
+			Configuration: out,
 		},
 		{
 			Name:          module.ControlPort,
@@ -175,19 +619,99 @@ func (t *Component) Ports() []module.Port {
 		},
 	}
 
+	if settings.EnableStartPort {
+		ports = append(ports, module.Port{
+			Position:      module.Left,
+			Name:          StartPort,
+			Label:         "Start",
+			Source:        true,
+			Configuration: Start{},
+		})
+	}
+
+	if settings.OnError == OnErrorEmit {
+		ports = append(ports, module.Port{
+			Position:      module.Bottom,
+			Name:          ErrorPort,
+			Label:         "Error",
+			Source:        false,
+			Configuration: TickError{},
+		})
+	}
+
+	if settings.Adaptive.Enabled {
+		ports = append(ports, module.Port{
+			Position:      module.Left,
+			Name:          FeedbackPort,
+			Label:         "Feedback",
+			Source:        true,
+			Configuration: Feedback{},
+		})
+	}
+
+	if settings.Backoff.EnableResetPort {
+		ports = append(ports, module.Port{
+			Position:      module.Left,
+			Name:          ResetPort,
+			Label:         "Reset delay",
+			Source:        true,
+			Configuration: Reset{},
+		})
+	}
+
+	ports = append(ports, module.Port{
+		Position:      module.Bottom,
+		Name:          PausePort,
+		Label:         "Pause",
+		Source:        true,
+		Configuration: Pause{},
+	}, module.Port{
+		Position:      module.Bottom,
+		Name:          ResumePort,
+		Label:         "Resume",
+		Source:        true,
+		Configuration: Resume{},
+	})
+
+	// programmatically stop ticker
+	if settings.EnableStopPort {
+		ports = append(ports, module.Port{
+			Position:      module.Bottom,
+			Name:          StopPort,
+			Label:         "Stop",
+			Source:        true,
+			Configuration: Stop{},
+		})
+	}
+
 	return ports
 }
 
 func (t *Component) getControl() interface{} {
+	settings := t.getSettings()
+	if t.isPaused() {
+		return PausedControl{
+			Context: settings.Context,
+			Status:  fmt.Sprintf("Paused (%d ticks)", t.getCount()),
+			Paused:  true,
+		}
+	}
 	if t.isRunning() {
 		return StopControl{
-			Status:  "Running",
-			Context: t.settings.Context,
+			Status:   fmt.Sprintf("Running every %v", t.getDelay()),
+			Context:  settings.Context,
+			Delay:    int(t.getDelay().Milliseconds()),
+			Ticks:    t.getCount(),
+			LastTick: t.getLastTick(),
 		}
 	}
+	status := "Not running"
+	if finished := t.getFinished(); finished != "" {
+		status = finished
+	}
 	return StartControl{
-		Context: t.settings.Context,
-		Status:  "Not running",
+		Context: settings.Context,
+		Status:  status,
 	}
 }
 
@@ -0,0 +1,505 @@
+package ticker
+
+import (
+	"context"
+	"fmt"
+	"github.com/tiny-systems/common-module/internal/clock"
+	"github.com/tiny-systems/module/module"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestComponent_AutoDoesNotBlock(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	var ticks atomic.Int32
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			ticks.Add(1)
+		}
+		return nil
+	}
+
+	for _, delay := range []int{1000, 10} {
+		done := make(chan error)
+		go func() {
+			done <- c.Handle(context.Background(), handler, module.SettingsPort, Settings{Delay: delay, Auto: true})
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("settings error: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Handle blocked with auto enabled")
+		}
+	}
+
+	time.Sleep(105 * time.Millisecond)
+	_ = c.stop()
+
+	// the running loop should pick up the new 10ms delay
+	if n := ticks.Load(); n < 5 {
+		t.Errorf("expected restarted loop to tick with new delay, got %d ticks", n)
+	}
+}
+
+func TestComponent_MaxCount(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	var ticks atomic.Int32
+	_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			ticks.Add(1)
+		}
+		return nil
+	}, module.SettingsPort, Settings{Delay: 5, Auto: true, MaxCount: 3})
+
+	time.Sleep(100 * time.Millisecond)
+
+	if n := ticks.Load(); n != 3 {
+		t.Errorf("expected 3 ticks, got %d", n)
+	}
+	control, ok := c.getControl().(StartControl)
+	if !ok {
+		t.Fatalf("ticker should not be running after reaching max count")
+	}
+	if control.Status != "Completed (3 ticks)" {
+		t.Errorf("unexpected status: %s", control.Status)
+	}
+}
+
+func TestComponent_EmitTickInfo(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	ticks := make(chan TickInfo, 3)
+	_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			ticks <- data.(TickInfo)
+		}
+		return nil
+	}, module.SettingsPort, Settings{Delay: 5, Auto: true, MaxCount: 3, EmitTickInfo: true, Context: "ctx"})
+
+	for i := 1; i <= 3; i++ {
+		select {
+		case tick := <-ticks:
+			if tick.Tick != i || tick.Context != "ctx" || tick.FiredAt.IsZero() {
+				t.Errorf("unexpected tick info: %+v", tick)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("tick %d was not emitted", i)
+		}
+	}
+}
+
+func TestComponent_StartStopPorts(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{Delay: 5, EnableStartPort: true, EnableStopPort: true})
+
+	var ticks atomic.Int32
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			ticks.Add(1)
+		}
+		return nil
+	}
+
+	if err := c.Handle(context.Background(), handler, StartPort, Start{}); err != nil {
+		t.Fatalf("start error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := c.Handle(context.Background(), handler, StopPort, Stop{}); err != nil {
+		t.Fatalf("stop error: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	stopped := ticks.Load()
+	if stopped == 0 {
+		t.Fatal("ticker did not tick after start")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if ticks.Load() != stopped {
+		t.Error("ticker kept ticking after stop")
+	}
+	if c.isRunning() {
+		t.Error("ticker is still running")
+	}
+}
+
+func TestComponent_ChangeDelayWhileRunning(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	ticks := make(chan TickInfo, 10)
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			ticks <- data.(TickInfo)
+		}
+		return nil
+	}
+
+	started := time.Now()
+	_ = c.Handle(context.Background(), handler, module.SettingsPort, Settings{Delay: 5000, Auto: true, EmitTickInfo: true})
+	time.Sleep(20 * time.Millisecond)
+	_ = c.Handle(context.Background(), handler, module.SettingsPort, Settings{Delay: 30, Auto: true, EmitTickInfo: true})
+	defer c.stop()
+
+	for i := 1; i <= 2; i++ {
+		select {
+		case tick := <-ticks:
+			// tick numbers keep growing, the loop was not restarted
+			if tick.Tick != i {
+				t.Errorf("expected tick %d, got %d", i, tick.Tick)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("tick %d was not emitted using the new delay", i)
+		}
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("in-flight tick was not rescheduled, took %v", elapsed)
+	}
+}
+
+func TestComponent_OnError(t *testing.T) {
+	t.Run("stop", func(t *testing.T) {
+		c := (&Component{}).Instance().(*Component)
+
+		var ticks atomic.Int32
+		_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+			if port == OutPort {
+				ticks.Add(1)
+				return fmt.Errorf("sink is broken")
+			}
+			return nil
+		}, module.SettingsPort, Settings{Delay: 5, Auto: true, OnError: OnErrorStop})
+
+		time.Sleep(50 * time.Millisecond)
+
+		if n := ticks.Load(); n != 1 {
+			t.Errorf("expected ticker to stop after first error, got %d ticks", n)
+		}
+		control, ok := c.getControl().(StartControl)
+		if !ok || control.Status != "Stopped on error: sink is broken" {
+			t.Errorf("unexpected control: %+v", c.getControl())
+		}
+	})
+
+	t.Run("emit", func(t *testing.T) {
+		c := (&Component{}).Instance().(*Component)
+
+		errs := make(chan TickError, 10)
+		_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+			switch port {
+			case OutPort:
+				return fmt.Errorf("sink is broken")
+			case ErrorPort:
+				errs <- data.(TickError)
+			}
+			return nil
+		}, module.SettingsPort, Settings{Delay: 5, Auto: true, OnError: OnErrorEmit, Context: "ctx"})
+		defer c.stop()
+
+		for i := 1; i <= 2; i++ {
+			select {
+			case e := <-errs:
+				if e.Tick != i || e.Error != "sink is broken" || e.Context != "ctx" {
+					t.Errorf("unexpected tick error: %+v", e)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("ticker did not keep going after error")
+			}
+		}
+	})
+}
+
+func TestComponent_Backoff(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Delay:   1000,
+		Backoff: Backoff{Factor: 2, MaxDelayMs: 4000, EnableResetPort: true},
+	})
+
+	var got []time.Duration
+	for i := 0; i < 4; i++ {
+		got = append(got, c.getDelay())
+		c.rampDelay()
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("unexpected delays: %v", got)
+		}
+	}
+
+	_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		return nil
+	}, ResetPort, Reset{})
+	if d := c.getDelay(); d != time.Second {
+		t.Errorf("expected delay to be reset to base, got %v", d)
+	}
+}
+
+func TestComponent_FireOnStart(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	ticks := make(chan TickInfo, 3)
+	started := time.Now()
+	_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			ticks <- data.(TickInfo)
+		}
+		return nil
+	}, module.SettingsPort, Settings{Delay: 5000, Auto: true, FireOnStart: true, MaxCount: 1, EmitTickInfo: true})
+
+	select {
+	case tick := <-ticks:
+		if tick.Tick != 1 {
+			t.Errorf("expected first tick, got %d", tick.Tick)
+		}
+		if elapsed := time.Since(started); elapsed > time.Second {
+			t.Errorf("first tick waited for the delay: %v", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ticker did not fire on start")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	control, ok := c.getControl().(StartControl)
+	if !ok || control.Status != "Completed (1 ticks)" {
+		t.Errorf("immediate tick should count towards max count: %+v", c.getControl())
+	}
+}
+
+func TestComponent_Interval(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	if err := c.Handle(context.Background(), nil, module.SettingsPort, Settings{Delay: 30, Interval: "30s"}); err != nil {
+		t.Fatalf("settings error: %v", err)
+	}
+	if d := c.getDelay(); d != 30*time.Second {
+		t.Errorf("expected interval to win over delay, got %v", d)
+	}
+
+	if err := c.Handle(context.Background(), nil, module.SettingsPort, Settings{Delay: 30, Interval: "30 seconds"}); err == nil {
+		t.Error("expected error for unparsable interval")
+	}
+
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{Delay: 30})
+	if d := c.getDelay(); d != 30*time.Millisecond {
+		t.Errorf("expected delay to be used without interval, got %v", d)
+	}
+}
+
+func TestComponent_PauseResume(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	ticks := make(chan TickInfo, 10)
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			ticks <- data.(TickInfo)
+		}
+		return nil
+	}
+	_ = c.Handle(context.Background(), handler, module.SettingsPort, Settings{Delay: 5, Auto: true, EmitTickInfo: true})
+	defer c.stop()
+
+	for i := 1; i <= 2; i++ {
+		<-ticks
+	}
+	if err := c.Handle(context.Background(), handler, module.ControlPort, StopControl{Pause: true}); err != nil {
+		t.Fatalf("pause error: %v", err)
+	}
+	if !c.isRunning() {
+		t.Fatal("paused ticker should keep running")
+	}
+
+	paused := c.getCount()
+	control, ok := c.getControl().(PausedControl)
+	if !ok || !control.Paused || control.Status != fmt.Sprintf("Paused (%d ticks)", paused) {
+		t.Fatalf("unexpected control: %+v", c.getControl())
+	}
+	// tick in flight may have been sent already
+	time.Sleep(20 * time.Millisecond)
+	for len(ticks) > 0 {
+		<-ticks
+	}
+	paused = c.getCount()
+
+	_ = c.Handle(context.Background(), handler, module.ControlPort, PausedControl{Resume: true})
+	select {
+	case tick := <-ticks:
+		if tick.Tick != paused+1 {
+			t.Errorf("expected resumed ticker to continue from %d, got %d", paused+1, tick.Tick)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ticker was not resumed")
+	}
+	if control, ok := c.getControl().(StopControl); !ok || control.Paused {
+		t.Errorf("unexpected control after resume: %+v", c.getControl())
+	}
+}
+
+func TestComponent_PauseResumePorts(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	var ticks atomic.Int32
+	handler := func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			ticks.Add(1)
+		}
+		return nil
+	}
+	_ = c.Handle(context.Background(), handler, module.SettingsPort, Settings{Delay: 5, Auto: true})
+	defer c.stop()
+
+	time.Sleep(30 * time.Millisecond)
+	if err := c.Handle(context.Background(), handler, PausePort, Pause{}); err != nil {
+		t.Fatalf("pause error: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	paused := ticks.Load()
+	if paused == 0 {
+		t.Fatal("ticker did not tick before pause")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if ticks.Load() != paused {
+		t.Error("ticker kept ticking while paused")
+	}
+	if !c.isRunning() {
+		t.Error("paused ticker should keep running")
+	}
+
+	if err := c.Handle(context.Background(), handler, ResumePort, Resume{}); err != nil {
+		t.Fatalf("resume error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if ticks.Load() == paused {
+		t.Error("ticker did not tick after resume")
+	}
+}
+
+func TestComponent_Heartbeat(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+
+	controls := make(chan StopControl, 10)
+	started := time.Now()
+	_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		if port == module.ReconcilePort {
+			if control, ok := c.getControl().(StopControl); ok {
+				controls <- control
+			}
+		}
+		return nil
+	}, module.SettingsPort, Settings{Delay: 5, Auto: true})
+	defer c.stop()
+
+	for {
+		select {
+		case control := <-controls:
+			if control.Ticks == 0 {
+				// reconcile on loop start
+				continue
+			}
+			if control.Ticks != 1 || control.LastTick.Before(started) {
+				t.Errorf("unexpected control after first tick: %+v", control)
+			}
+			return
+		case <-time.After(time.Second):
+			t.Fatal("dashboard was not refreshed after tick")
+		}
+	}
+}
+
+func TestComponent_Adaptive(t *testing.T) {
+	c := (&Component{}).Instance().(*Component)
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Delay: 1000,
+		Adaptive: Adaptive{
+			Enabled:         true,
+			MaxLatencyMs:    500,
+			TargetLatencyMs: 100,
+			MinDelayMs:      950,
+			MaxDelayMs:      1150,
+		},
+	})
+	var reconciled int
+	noop := func(ctx context.Context, port string, data interface{}) error {
+		if port == module.ReconcilePort {
+			reconciled++
+		}
+		return nil
+	}
+
+	var got []time.Duration
+	for i := 0; i < 3; i++ {
+		_ = c.Handle(context.Background(), noop, FeedbackPort, Feedback{Latency: time.Second})
+		got = append(got, c.getDelay())
+	}
+	want := []time.Duration{1100 * time.Millisecond, 1150 * time.Millisecond, 1150 * time.Millisecond}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("unexpected delays on high latency: %v", got)
+		}
+	}
+	if reconciled != 2 {
+		t.Errorf("expected reconcile only when delay changed, got %d", reconciled)
+	}
+
+	_ = c.Handle(context.Background(), noop, FeedbackPort, Feedback{Latency: 300 * time.Millisecond})
+	if d := c.getDelay(); d != 1150*time.Millisecond {
+		t.Errorf("delay should not change within latency bounds, got %v", d)
+	}
+
+	for i := 0; i < 5; i++ {
+		_ = c.Handle(context.Background(), noop, FeedbackPort, Feedback{Latency: 10 * time.Millisecond})
+	}
+	if d := c.getDelay(); d != 950*time.Millisecond {
+		t.Errorf("expected delay to shrink down to min, got %v", d)
+	}
+
+	// tiny delay grows too
+	_ = c.Handle(context.Background(), nil, module.SettingsPort, Settings{
+		Interval: "5ns",
+		Adaptive: Adaptive{Enabled: true, MaxLatencyMs: 500},
+	})
+	_ = c.Handle(context.Background(), noop, FeedbackPort, Feedback{Latency: time.Second})
+	if d := c.getDelay(); d != 5*time.Nanosecond+time.Millisecond {
+		t.Errorf("expected tiny delay to grow by min step, got %v", d)
+	}
+}
+
+func TestComponent_FakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFakeClock(start)
+
+	c := (&Component{}).Instance().(*Component)
+	c.clock = fake
+
+	ticks := make(chan TickInfo, 3)
+	_ = c.Handle(context.Background(), func(ctx context.Context, port string, data interface{}) error {
+		if port == OutPort {
+			ticks <- data.(TickInfo)
+		}
+		return nil
+	}, module.SettingsPort, Settings{Delay: 60000, Auto: true, MaxCount: 3, EmitTickInfo: true})
+
+	for i := 1; i <= 3; i++ {
+		fake.BlockUntil(1)
+		select {
+		case tick := <-ticks:
+			t.Fatalf("tick %d emitted before clock advanced", tick.Tick)
+		default:
+		}
+
+		fake.Advance(time.Minute)
+		select {
+		case tick := <-ticks:
+			if want := start.Add(time.Duration(i) * time.Minute); tick.Tick != i || !tick.FiredAt.Equal(want) {
+				t.Errorf("unexpected tick info: %+v", tick)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("tick %d was not emitted", i)
+		}
+	}
+}
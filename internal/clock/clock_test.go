@@ -0,0 +1,76 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+
+	first := c.After(time.Second)
+	second := c.After(2 * time.Second)
+	c.BlockUntil(2)
+
+	c.Advance(time.Second)
+	select {
+	case at := <-first:
+		if !at.Equal(start.Add(time.Second)) {
+			t.Errorf("unexpected fire time: %v", at)
+		}
+	default:
+		t.Fatal("first waiter should have fired")
+	}
+	select {
+	case <-second:
+		t.Fatal("second waiter fired too early")
+	default:
+	}
+
+	c.Advance(time.Second)
+	select {
+	case <-second:
+	default:
+		t.Fatal("second waiter should have fired")
+	}
+	if !c.Now().Equal(start.Add(2 * time.Second)) {
+		t.Errorf("unexpected now: %v", c.Now())
+	}
+}
+
+func TestFakeClock_Stop(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	stopped := c.NewTimer(time.Second)
+	if !stopped.Stop() {
+		t.Error("waiting timer should be stopped")
+	}
+	if stopped.Stop() {
+		t.Error("timer can not be stopped twice")
+	}
+
+	blocked := make(chan struct{})
+	go func() {
+		c.BlockUntil(1)
+		close(blocked)
+	}()
+	select {
+	case <-blocked:
+		t.Fatal("stopped timer should not be counted as waiting")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	timer := c.NewTimer(time.Second)
+	<-blocked
+
+	c.Advance(time.Second)
+	select {
+	case <-stopped.C():
+		t.Error("stopped timer fired")
+	case <-timer.C():
+	}
+	if timer.Stop() {
+		t.Error("fired timer can not be stopped")
+	}
+}
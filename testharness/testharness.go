@@ -0,0 +1,31 @@
+package testharness
+
+import (
+	"github.com/tiny-systems/module/module"
+	"testing"
+)
+
+// Validator is implemented by settings which can be validated without being delivered to the component
+type Validator interface {
+	ValidateSettings() error
+}
+
+// AssertValidSettings checks that default settings of the component's settings port are valid
+func AssertValidSettings(t testing.TB, component module.Component) {
+	t.Helper()
+
+	for _, port := range component.Ports() {
+		if port.Name != module.SettingsPort {
+			continue
+		}
+		v, ok := port.Configuration.(Validator)
+		if !ok {
+			t.Fatalf("settings %T do not implement validator", port.Configuration)
+		}
+		if err := v.ValidateSettings(); err != nil {
+			t.Fatalf("default settings are invalid: %v", err)
+		}
+		return
+	}
+	t.Fatalf("component %s has no settings port", component.GetInfo().Name)
+}